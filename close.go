@@ -0,0 +1,63 @@
+package timeout
+
+import "sync"
+
+// runState holds resources of a run to be released by Close
+type runState struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	closeCh chan struct{}
+	closed  bool
+}
+
+func (rs *runState) init() {
+	if rs.closeCh == nil {
+		rs.closeCh = make(chan struct{})
+	}
+}
+
+// reset makes the state reusable for the next run after closed
+func (rs *runState) reset() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.closed {
+		rs.closeCh = nil
+		rs.closed = false
+	}
+}
+
+func (rs *runState) closing() <-chan struct{} {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.init()
+	return rs.closeCh
+}
+
+// goFunc runs f in a goroutine which Close waits for
+func (rs *runState) goFunc(f func()) {
+	rs.mu.Lock()
+	rs.wg.Add(1)
+	rs.mu.Unlock()
+	go func() {
+		defer rs.wg.Done()
+		f()
+	}()
+}
+
+// Close releases all resources of the run. The command is killed if it is
+// still running, and Close returns after all internal goroutines exit.
+// Run, RunSimple, RunContext and RunResult call it before returning, but
+// it has to be called explicitly when using RunCommand.
+func (tio *Timeout) Close() error {
+	rs := &tio.state
+	rs.mu.Lock()
+	rs.init()
+	if !rs.closed {
+		rs.closed = true
+		close(rs.closeCh)
+	}
+	rs.mu.Unlock()
+	rs.wg.Wait()
+	tio.closeOutputs()
+	return nil
+}
@@ -0,0 +1,45 @@
+package timeout
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	tio := &Timeout{
+		Cmd:       exec.Command(stubCmd, "-sleep", "10"),
+		Duration:  10 * time.Second,
+		KillAfter: 10 * time.Second,
+	}
+	ch, err := tio.RunCommand()
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	start := time.Now()
+	if err := tio.Close(); err != nil {
+		t.Errorf("error should be nil but: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("the command should be killed by Close but took: %s", elapsed)
+	}
+	st := <-ch
+	if !st.IsKilled() {
+		t.Errorf("the command should be killed")
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines should be exited. before: %d, after: %d", before, after)
+	}
+}
+
+func TestClose_reuse(t *testing.T) {
+	tio := &Timeout{Duration: 10 * time.Second}
+	for i := 0; i < 2; i++ {
+		tio.Cmd = exec.Command(stubCmd)
+		if exit := tio.RunSimple(false); exit != 0 {
+			t.Errorf("expected exitcode: 0, but: %d", exit)
+		}
+	}
+}
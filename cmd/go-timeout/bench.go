@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Songmu/timeout"
+)
+
+type benchReport struct {
+	Runs     int     `json:"runs"`
+	TimedOut int     `json:"timedOut"`
+	Failed   int     `json:"failed"`
+	Min      float64 `json:"min"`
+	Mean     float64 `json:"mean"`
+	P95      float64 `json:"p95"`
+	Max      float64 `json:"max"`
+}
+
+// bench runs the command n times under the Timeout and summarizes the wall
+// times. The Timeout is reused with a new Cmd for each run.
+func bench(tio *timeout.Timeout, args []string, n int) (*benchReport, error) {
+	elapsed := make([]time.Duration, 0, n)
+	rep := &benchReport{Runs: n}
+	for i := 0; i < n; i++ {
+		tio.Cmd = exec.Command(args[0], args[1:]...)
+		res, err := tio.RunResult(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		elapsed = append(elapsed, res.Elapsed())
+		switch {
+		case res.TimedOut:
+			rep.TimedOut++
+		case res.ExitCode != 0:
+			rep.Failed++
+		}
+	}
+	sort.Slice(elapsed, func(i, j int) bool { return elapsed[i] < elapsed[j] })
+	var total time.Duration
+	for _, d := range elapsed {
+		total += d
+	}
+	rep.Min = elapsed[0].Seconds()
+	rep.Max = elapsed[n-1].Seconds()
+	rep.Mean = (total / time.Duration(n)).Seconds()
+	// nearest-rank method
+	rep.P95 = elapsed[int(math.Ceil(0.95*float64(n)))-1].Seconds()
+	return rep, nil
+}
+
+// print prints the report in the format of "table" or "json"
+func (rep *benchReport) print(w io.Writer, format string) error {
+	if format == "json" {
+		return json.NewEncoder(w).Encode(rep)
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "runs\ttimed out\tfailed\tmin\tmean\tp95\tmax")
+	fmt.Fprintf(tw, "%d\t%d\t%d\t%.3fs\t%.3fs\t%.3fs\t%.3fs\n",
+		rep.Runs, rep.TimedOut, rep.Failed, rep.Min, rep.Mean, rep.P95, rep.Max)
+	return tw.Flush()
+}
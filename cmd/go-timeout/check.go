@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Songmu/timeout"
+)
+
+// exit statuses of check plugins
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+)
+
+var checkStatusNames = map[int]string{
+	checkOK:       "OK",
+	checkWarning:  "WARNING",
+	checkCritical: "CRITICAL",
+}
+
+// checkResult translates the result into a check plugin style message and exit status.
+// The run is WARNING when it succeeded but took longer than warning (if positive).
+func checkResult(res *timeout.Result, dur, warning time.Duration) (string, int) {
+	st, msg := checkStatus(res, dur, warning)
+	return fmt.Sprintf("%s: %s", checkStatusNames[st], msg), st
+}
+
+func checkStatus(res *timeout.Result, dur, warning time.Duration) (int, string) {
+	elapsed := res.Elapsed()
+	switch {
+	case res.ExitStatus == nil:
+		return checkCritical, res.Result
+	case res.TimedOut && res.Trigger != "":
+		return checkCritical, fmt.Sprintf("timed out after %s: %s", formatSeconds(elapsed), res.Trigger)
+	case res.TimedOut:
+		return checkCritical, fmt.Sprintf("timed out after %s", formatSeconds(dur))
+	case res.ExitCode != 0:
+		return checkCritical, fmt.Sprintf("job exited with code %d in %s", res.ExitCode, formatSeconds(elapsed))
+	case warning > 0 && elapsed > warning:
+		return checkWarning, fmt.Sprintf("job finished in %s, longer than %s", formatSeconds(elapsed), formatSeconds(warning))
+	default:
+		return checkOK, fmt.Sprintf("job finished in %s", formatSeconds(elapsed))
+	}
+}
+
+// attemptDuration returns the duration of the last attempt in res, which is
+// clamped to the remaining budget of the chain
+func attemptDuration(fb *timeout.Fallback, res *timeout.Result) time.Duration {
+	if len(res.Attempts) == 0 {
+		return fb.Timeouts[0].Duration
+	}
+	last := res.Attempts[len(res.Attempts)-1]
+	dur := fb.Timeouts[len(res.Attempts)-1].Duration
+	if fb.Budget > 0 {
+		if remaining := res.StartAt.Add(fb.Budget).Sub(last.StartAt); remaining < dur {
+			dur = remaining
+		}
+	}
+	return dur
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Round(time.Millisecond).Seconds(), 'f', -1, 64) + "s"
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// encodingByName looks up the encoding by its WHATWG or IANA names, such as
+// "shift_jis", "windows-31j" or "cp932"
+func encodingByName(name string) (encoding.Encoding, error) {
+	// Windows code pages are often called like "cp1252"
+	if lower := strings.ToLower(name); lower == "cp932" || lower == "ms932" {
+		name = "windows-31j"
+	} else if strings.HasPrefix(lower, "cp") {
+		if enc, err := htmlindex.Get("windows-" + lower[2:]); err == nil {
+			return enc, nil
+		}
+	}
+	if enc, err := htmlindex.Get(name); err == nil {
+		return enc, nil
+	}
+	if enc, err := ianaindex.IANA.Encoding(name); err == nil && enc != nil {
+		return enc, nil
+	}
+	return nil, fmt.Errorf("%s: unknown encoding", name)
+}
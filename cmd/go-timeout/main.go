@@ -1,15 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"time"
 
 	"github.com/Songmu/timeout"
 	"github.com/pborman/getopt"
+	"golang.org/x/text/encoding"
 )
 
 func main() {
@@ -17,6 +20,29 @@ func main() {
 	optSig := getopt.StringLong("signal", 's', "", "specify the signal to be sent on timeout. IGNAL may be a name like 'HUP' or a number. see 'kill -l' for a list of signals")
 	optForeground := getopt.BoolLong("foreground", 0, "when not running timeout directly from a shell prompt, allow COMMAND to read from the TTY and get TTY signals. in this mode, children of COMMAND will not be timed out")
 	p := getopt.BoolLong("preserve-status", 0, "exit with the same status as COMMAND, even when the command times out")
+	optUnshare := getopt.StringLong("unshare", 0, "", "unshare the namespaces for COMMAND. comma separated list of 'mount', 'net' and 'ipc'. mounts in the new mount namespace are private (Linux only)")
+	var reporters stringsValue
+	getopt.VarLong(&reporters, "reporter", 0, "pipe the JSON result of the run to stdin of CMD after the run. can be specified multiple times", "CMD")
+	optCheck := getopt.BoolLong("check", 0, "print a check plugin style line instead of the output of COMMAND and exit with 0 (OK), 1 (WARNING) or 2 (CRITICAL)")
+	optCheckWarning := getopt.StringLong("check-warning", 0, "", "with --check, report WARNING when COMMAND succeeded but took longer than this", "DURATION")
+	optStatusFile := getopt.StringLong("status-file", 0, "", "write the status of the run as JSON to the file atomically when the run ends", "PATH")
+	optChecksum := getopt.BoolLong("checksum", 0, "include SHA-256 checksums of stdout and stderr of COMMAND in the results for --reporter and --status-file")
+	optHistory := getopt.StringLong("history", 0, "", "append the status of the run as a line of JSON to the history file", "PATH")
+	optValidate := getopt.BoolLong("validate", 0, "validate COMMAND and the options without running COMMAND, and exit with 0 if they are valid")
+	optStats := getopt.BoolLong("stats", 0, "print wall time, CPU times, max RSS and whether the limit was hit to stderr after the run")
+	optStatsFormat := getopt.EnumLong("stats-format", 0, []string{"human", "json"}, "format of --stats, 'human' (default) or 'json'", "FORMAT")
+	optEncoding := getopt.StringLong("encoding", 0, "", "convert the outputs of COMMAND from the encoding to UTF-8. ex. 'shift_jis', 'cp932' or 'windows-1252'", "NAME")
+	optIdle := getopt.StringLong("idle-timeout", 0, "", "also terminate COMMAND when it writes nothing to stdout and stderr for this long", "DURATION")
+	optMemory := getopt.StringLong("memory-limit", 0, "", "also terminate COMMAND when the resident set size of its process group exceeds this. suffixes K, M and G are allowed (Linux only)", "SIZE")
+	optCPU := getopt.StringLong("cpu-limit", 0, "", "also terminate COMMAND when the CPU time of its process group exceeds this (Linux only)", "DURATION")
+	optHeartbeatFile := getopt.StringLong("heartbeat-file", 0, "", "also terminate COMMAND when it does not touch the file for --heartbeat-interval", "PATH")
+	optHeartbeatInterval := getopt.StringLong("heartbeat-interval", 0, "60", "interval of --heartbeat-file", "DURATION")
+	optBench := getopt.IntLong("bench", 0, 0, "run COMMAND N times without its output and report min/mean/p95/max of the wall times and how many runs timed out", "N")
+	optBenchFormat := getopt.EnumLong("bench-format", 0, []string{"table", "json"}, "format of --bench, 'table' (default) or 'json'", "FORMAT")
+	optService := getopt.StringLong("service", 0, "", "run as the Windows service of the name. Stop requests from the service control manager terminate COMMAND by CTRL_BREAK_EVENT and kill it after --kill-after (Windows only)", "NAME")
+	var fallbacks stringsValue
+	getopt.VarLong(&fallbacks, "fallback", 0, "run CMD when COMMAND fails to start, times out or exits with non-zero. can be specified multiple times to make a chain", "CMD")
+	optBudget := getopt.StringLong("budget", 0, "", "with --fallback, the time for the whole chain. each command is limited to the remaining time", "DURATION")
 
 	opts := getopt.CommandLine
 	opts.Parse(os.Args)
@@ -39,7 +65,49 @@ func main() {
 
 	var sig os.Signal
 	if *optSig != "" {
-		sig, err = parseSignal(*optSig)
+		sig, err = timeout.ParseSignal(*optSig)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(125)
+		}
+	}
+
+	var unshare timeout.Namespace
+	if *optUnshare != "" {
+		unshare, err = timeout.ParseNamespace(*optUnshare)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(125)
+		}
+	}
+
+	var enc encoding.Encoding
+	if *optEncoding != "" {
+		enc, err = encodingByName(*optEncoding)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(125)
+		}
+	}
+
+	watchers, err := parseWatchers(*optIdle, *optMemory, *optCPU, *optHeartbeatFile, *optHeartbeatInterval)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(125)
+	}
+
+	checkWarning := float64(0)
+	if *optCheckWarning != "" {
+		checkWarning, err = parseDuration(*optCheckWarning)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(125)
+		}
+	}
+
+	budget := float64(0)
+	if *optBudget != "" {
+		budget, err = parseDuration(*optBudget)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err.Error())
 			os.Exit(125)
@@ -52,41 +120,202 @@ func main() {
 		os.Exit(125)
 	}
 
-	cmd := exec.Command(rest[1], rest[2:]...)
+	newTimeout := func(cmd *exec.Cmd) *timeout.Timeout {
+		if !*optCheck && *optBench == 0 {
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+		}
+		return &timeout.Timeout{
+			Duration:   time.Duration(dur * float64(time.Second)),
+			Cmd:        cmd,
+			Foreground: *optForeground,
+			KillAfter:  time.Duration(killAfter * float64(time.Second)),
+			Signal:     sig,
+			Unshare:    unshare,
+			Checksum:   *optChecksum,
+			NoCapture:  len(reporters) == 0 && !*optCheck && !*optChecksum,
+
+			Watchers:       watchers,
+			OutputEncoding: enc,
+		}
+	}
+	tio := newTimeout(exec.Command(rest[1], rest[2:]...))
+	fb := &timeout.Fallback{
+		Timeouts: []*timeout.Timeout{tio},
+		Budget:   secondsToDuration(budget),
+	}
+	for _, f := range fallbacks {
+		fb.Timeouts = append(fb.Timeouts, newTimeout(shellCommand(f)))
+	}
+	if *optValidate {
+		for _, t := range fb.Timeouts {
+			if err := validate(t, *optStatusFile, *optHistory); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(errorExitCode(err))
+			}
+		}
+		os.Exit(0)
+	}
+
+	if *optBench > 0 {
+		if len(fallbacks) > 0 {
+			fmt.Fprintln(os.Stderr, "--bench cannot be used with --fallback")
+			os.Exit(125)
+		}
+		rep, err := bench(tio, rest[1:], *optBench)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(errorExitCode(err))
+		}
+		rep.print(os.Stdout, *optBenchFormat)
+		os.Exit(0)
+	}
+
+	run := func(ctx context.Context) int {
+		res, err := fb.RunResult(ctx)
+		if err != nil && !*optCheck {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		if *optStats {
+			printStats(os.Stderr, res, *optStatsFormat)
+		}
+		report(res, reporters)
+		if *optStatusFile != "" {
+			if err := writeStatusFile(*optStatusFile, res); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+		if *optHistory != "" {
+			if err := appendHistory(*optHistory, res); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+		if *optCheck {
+			msg, st := checkResult(res, attemptDuration(fb, res), time.Duration(checkWarning*float64(time.Second)))
+			fmt.Println(msg)
+			return st
+		}
+		return exitCode(res, *p)
+	}
+
+	if *optService != "" {
+		for _, t := range fb.Timeouts {
+			if t.KillAfter > 0 {
+				t.KillAfterCancel = t.KillAfter
+			}
+		}
+		exit, err := runService(*optService, run)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(125)
+		}
+		os.Exit(exit)
+	}
+	os.Exit(run(context.Background()))
+}
 
-	tio := &timeout.Timeout{
-		Duration:   time.Duration(dur * float64(time.Second)),
-		Cmd:        cmd,
-		Foreground: *optForeground,
-		KillAfter:  time.Duration(killAfter * float64(time.Second)),
-		Signal:     sig,
+// validate checks the Timeout and the directories of output files exist
+func validate(tio *timeout.Timeout, outputs ...string) error {
+	if err := tio.Validate(); err != nil {
+		return err
 	}
-	exit := tio.RunSimple(*p)
-	os.Exit(exit)
+	for _, fname := range outputs {
+		if fname == "" {
+			continue
+		}
+		dir := filepath.Dir(fname)
+		fi, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("%s: not a directory", dir)
+		}
+	}
+	return nil
 }
 
-var durRe = regexp.MustCompile(`^([-0-9e.]+)([smhd])?$`)
+func errorExitCode(err error) int {
+	if tmerr, ok := err.(*timeout.Error); ok {
+		return tmerr.ExitCode
+	}
+	return 125
+}
 
-func parseDuration(durStr string) (float64, error) {
-	matches := durRe.FindStringSubmatch(durStr)
-	if len(matches) == 0 {
-		return 0, fmt.Errorf("duration format invalid: %s", durStr)
+func exitCode(res *timeout.Result, preserveStatus bool) int {
+	if res.ExitStatus == nil {
+		return res.ExitCode
+	}
+	if preserveStatus {
+		return res.ExitStatus.GetChildExitCode()
+	}
+	return res.ExitStatus.GetExitCode()
+}
+
+func secondsToDuration(sec float64) time.Duration {
+	return time.Duration(sec * float64(time.Second))
+}
+
+func parseWatchers(idle, memory, cpu, heartbeatFile, heartbeatInterval string) ([]timeout.Watcher, error) {
+	var watchers []timeout.Watcher
+	if idle != "" {
+		sec, err := parseDuration(idle)
+		if err != nil {
+			return nil, err
+		}
+		watchers = append(watchers, &timeout.IdleWatcher{Duration: secondsToDuration(sec)})
+	}
+	if memory != "" {
+		limit, err := parseSize(memory)
+		if err != nil {
+			return nil, err
+		}
+		watchers = append(watchers, &timeout.MemoryWatcher{Limit: limit})
+	}
+	if cpu != "" {
+		sec, err := parseDuration(cpu)
+		if err != nil {
+			return nil, err
+		}
+		watchers = append(watchers, &timeout.CPUTimeWatcher{Limit: secondsToDuration(sec)})
 	}
+	if heartbeatFile != "" {
+		sec, err := parseDuration(heartbeatInterval)
+		if err != nil {
+			return nil, err
+		}
+		watchers = append(watchers, &timeout.HeartbeatWatcher{Path: heartbeatFile, Interval: secondsToDuration(sec)})
+	}
+	return watchers, nil
+}
 
-	base, err := strconv.ParseFloat(matches[1], 64)
+var sizeRe = regexp.MustCompile(`^([0-9]+)([kKmMgG])?[bB]?$`)
+
+func parseSize(sizeStr string) (int64, error) {
+	matches := sizeRe.FindStringSubmatch(sizeStr)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("size format invalid: %s", sizeStr)
+	}
+	base, err := strconv.ParseInt(matches[1], 10, 64)
 	if err != nil {
-		return 0, fmt.Errorf("invalid time interval `%s`", durStr)
+		return 0, fmt.Errorf("invalid size `%s`", sizeStr)
 	}
 	switch matches[2] {
-	case "", "s":
+	case "":
 		return base, nil
-	case "m":
-		return base * 60, nil
-	case "h":
-		return base * 60 * 60, nil
-	case "d":
-		return base * 60 * 60 * 24, nil
+	case "k", "K":
+		return base << 10, nil
+	case "m", "M":
+		return base << 20, nil
 	default:
-		return 0, fmt.Errorf("invalid time interval `%s`", durStr)
+		return base << 30, nil
+	}
+}
+
+func parseDuration(durStr string) (float64, error) {
+	d, err := timeout.ParseDuration(durStr)
+	if err != nil {
+		return 0, err
 	}
+	return d.Seconds(), nil
 }
@@ -1,6 +1,18 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Songmu/timeout"
+)
 
 func TestParseDuration(t *testing.T) {
 	v, err := parseDuration("55s")
@@ -48,3 +60,274 @@ func TestParseDuration(t *testing.T) {
 		t.Errorf("something wrong")
 	}
 }
+
+func TestCheckResult(t *testing.T) {
+	start := time.Date(2019, 4, 21, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name    string
+		res     *timeout.Result
+		warning time.Duration
+		msg     string
+		st      int
+	}{
+		{
+			name: "ok",
+			res:  &timeout.Result{ExitStatus: &timeout.ExitStatus{}, EndAt: start.Add(42 * time.Second)},
+			msg:  "OK: job finished in 42s",
+			st:   checkOK,
+		},
+		{
+			name:    "warning",
+			res:     &timeout.Result{ExitStatus: &timeout.ExitStatus{}, EndAt: start.Add(42 * time.Second)},
+			warning: 30 * time.Second,
+			msg:     "WARNING: job finished in 42s, longer than 30s",
+			st:      checkWarning,
+		},
+		{
+			name: "failed",
+			res:  &timeout.Result{ExitStatus: &timeout.ExitStatus{Code: 3}, ExitCode: 3, EndAt: start.Add(time.Second)},
+			msg:  "CRITICAL: job exited with code 3 in 1s",
+			st:   checkCritical,
+		},
+		{
+			name: "timed out",
+			res:  &timeout.Result{ExitStatus: &timeout.ExitStatus{}, TimedOut: true, EndAt: start.Add(300 * time.Second)},
+			msg:  "CRITICAL: timed out after 300s",
+			st:   checkCritical,
+		},
+		{
+			name: "triggered by watcher",
+			res:  &timeout.Result{ExitStatus: &timeout.ExitStatus{}, TimedOut: true, Trigger: "no output for 10s", EndAt: start.Add(12 * time.Second)},
+			msg:  "CRITICAL: timed out after 12s: no output for 10s",
+			st:   checkCritical,
+		},
+		{
+			name: "failed to execute",
+			res:  &timeout.Result{Result: "failed to execute command: not found", EndAt: start},
+			msg:  "CRITICAL: failed to execute command: not found",
+			st:   checkCritical,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.res.StartAt = start
+			msg, st := checkResult(tc.res, 300*time.Second, tc.warning)
+			if msg != tc.msg {
+				t.Errorf("expected message: %q, but: %q", tc.msg, msg)
+			}
+			if st != tc.st {
+				t.Errorf("expected status: %d, but: %d", tc.st, st)
+			}
+		})
+	}
+}
+
+func TestAttemptDuration(t *testing.T) {
+	start := time.Date(2019, 4, 21, 0, 0, 0, 0, time.UTC)
+	fb := &timeout.Fallback{
+		Timeouts: []*timeout.Timeout{
+			{Duration: 10 * time.Second},
+			{Duration: 20 * time.Second},
+		},
+	}
+	first := &timeout.Result{StartAt: start}
+	second := &timeout.Result{StartAt: start.Add(10 * time.Second)}
+	testCases := []struct {
+		name     string
+		attempts []*timeout.Result
+		budget   time.Duration
+		expect   time.Duration
+	}{
+		{
+			name:     "first",
+			attempts: []*timeout.Result{first},
+			expect:   10 * time.Second,
+		},
+		{
+			name:     "fallback",
+			attempts: []*timeout.Result{first, second},
+			expect:   20 * time.Second,
+		},
+		{
+			name:     "clamped to the budget",
+			attempts: []*timeout.Result{first, second},
+			budget:   15 * time.Second,
+			expect:   5 * time.Second,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fb.Budget = tc.budget
+			res := &timeout.Result{StartAt: start, Attempts: tc.attempts}
+			if d := attemptDuration(fb, res); d != tc.expect {
+				t.Errorf("expected: %s, but: %s", tc.expect, d)
+			}
+		})
+	}
+}
+
+func TestWriteStatusFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "status.json")
+	start := time.Now()
+	res := &timeout.Result{
+		Command:  "sleep 3",
+		ExitCode: 143,
+		TimedOut: true,
+		StartAt:  start,
+		EndAt:    start.Add(time.Second),
+	}
+	if err := writeStatusFile(fname, res); err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st status
+	if err := json.Unmarshal(b, &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.ExitCode != 143 || !st.TimedOut || st.Elapsed != 1 {
+		t.Errorf("invalid status: %+v", st)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("temporary file should be removed but: %d files", len(files))
+	}
+}
+
+func TestAppendHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "history.jsonl")
+	for _, code := range []int{0, 124} {
+		res := &timeout.Result{ExitCode: code, TimedOut: code == 124}
+		if err := appendHistory(fname, res); err != nil {
+			t.Fatalf("error should be nil but: %s", err)
+		}
+	}
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("history should have 2 lines but: %d", len(lines))
+	}
+	var st status
+	if err := json.Unmarshal([]byte(lines[1]), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.ExitCode != 124 || !st.TimedOut {
+		t.Errorf("invalid history: %+v", st)
+	}
+}
+
+func TestPrintStats(t *testing.T) {
+	start := time.Now()
+	res := &timeout.Result{
+		StartAt:  start,
+		EndAt:    start.Add(1500 * time.Millisecond),
+		UserTime: 0.25,
+		MaxRSS:   2048 * 1024,
+		TimedOut: true,
+	}
+
+	var buf bytes.Buffer
+	if err := printStats(&buf, res, "human"); err != nil {
+		t.Fatal(err)
+	}
+	for _, expect := range []string{"real      1.500s", "user      0.250s", "maxrss    2048KB", "timed out true"} {
+		if !strings.Contains(buf.String(), expect) {
+			t.Errorf("stats should contain %q but:\n%s", expect, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := printStats(&buf, res, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var st stats
+	if err := json.Unmarshal(buf.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Real != 1.5 || st.MaxRSS != 2048*1024 || !st.TimedOut {
+		t.Errorf("invalid stats: %+v", st)
+	}
+}
+
+func TestEncodingByName(t *testing.T) {
+	for _, name := range []string{"shift_jis", "Shift_JIS", "windows-31j", "cp932", "windows-1252", "cp1252", "euc-jp"} {
+		if _, err := encodingByName(name); err != nil {
+			t.Errorf("%s: error should be nil but: %s", name, err)
+		}
+	}
+	if _, err := encodingByName("unknown-encoding"); err == nil {
+		t.Errorf("error should be occurred")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	testCases := []struct {
+		input  string
+		expect int64
+	}{
+		{"100", 100},
+		{"1K", 1024},
+		{"512M", 512 << 20},
+		{"2GB", 2 << 30},
+	}
+	for _, tc := range testCases {
+		v, err := parseSize(tc.input)
+		if err != nil {
+			t.Errorf("%s: error should be nil but: %s", tc.input, err)
+		}
+		if v != tc.expect {
+			t.Errorf("%s: expected: %d, but: %d", tc.input, tc.expect, v)
+		}
+	}
+	if _, err := parseSize("1T"); err == nil {
+		t.Errorf("error should be occurred")
+	}
+}
+
+func TestBench(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip on windows")
+	}
+	tio := &timeout.Timeout{Duration: 100 * time.Millisecond}
+	rep, err := bench(tio, []string{"sh", "-c", "exit 1"}, 3)
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if rep.Runs != 3 || rep.Failed != 3 || rep.TimedOut != 0 {
+		t.Errorf("invalid report: %+v", rep)
+	}
+	if !(rep.Min <= rep.Mean && rep.Mean <= rep.Max && rep.P95 <= rep.Max) {
+		t.Errorf("invalid report: %+v", rep)
+	}
+
+	rep, err = bench(tio, []string{"sleep", "3"}, 2)
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if rep.TimedOut != 2 {
+		t.Errorf("all runs should be timed out: %+v", rep)
+	}
+
+	var buf bytes.Buffer
+	rep.print(&buf, "table")
+	if !strings.HasPrefix(buf.String(), "runs  timed out  failed") {
+		t.Errorf("invalid table:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/Songmu/timeout"
+	"github.com/pborman/getopt"
+)
+
+// stringsValue is a getopt.Value which can be specified multiple times
+type stringsValue []string
+
+func (sv *stringsValue) Set(value string, opt getopt.Option) error {
+	*sv = append(*sv, value)
+	return nil
+}
+
+func (sv *stringsValue) String() string {
+	return strings.Join(*sv, ", ")
+}
+
+func shellCommand(cmdStr string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/c", cmdStr)
+	}
+	return exec.Command("sh", "-c", cmdStr)
+}
+
+// report pipes the JSON result to stdin of each reporter in parallel
+func report(res *timeout.Result, reporters []string) {
+	if len(reporters) == 0 {
+		return
+	}
+	js, err := json.Marshal(res)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	var wg sync.WaitGroup
+	for _, r := range reporters {
+		wg.Add(1)
+		go func(r string) {
+			defer wg.Done()
+			cmd := shellCommand(r)
+			cmd.Stdin = bytes.NewReader(js)
+			cmd.Stdout = os.Stderr
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "reporter %q failed: %s\n", r, err)
+			}
+		}(r)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,12 @@
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+func runService(name string, run func(context.Context) int) (int, error) {
+	return 0, fmt.Errorf("service mode is supported on Windows only")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+)
+
+var procAllocConsole = windows.NewLazySystemDLL("kernel32.dll").NewProc("AllocConsole")
+
+// timeoutService runs the command as a Windows service. Stop and Shutdown
+// requests are translated into cancellation of the run, which sends
+// CTRL_BREAK_EVENT to the command and kills it after the grace period.
+type timeoutService struct {
+	run  func(context.Context) int
+	exit int
+}
+
+func (ts *timeoutService) Execute(args []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	s <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan int, 1)
+	go func() {
+		done <- ts.run(ctx)
+	}()
+
+	const accepts = svc.AcceptStop | svc.AcceptShutdown
+	s <- svc.Status{State: svc.Running, Accepts: accepts}
+	for {
+		select {
+		case ts.exit = <-done:
+			s <- svc.Status{State: svc.StopPending}
+			if ts.exit != 0 {
+				// report the exit code of the command as the service specific exit code
+				return true, uint32(ts.exit)
+			}
+			return false, 0
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				s <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				s <- svc.Status{State: svc.StopPending}
+				cancel()
+			}
+		}
+	}
+}
+
+// runService runs the command as the Windows service of the name. It runs the
+// command directly when it is invoked from an interactive session.
+func runService(name string, run func(context.Context) int) (int, error) {
+	interactive, err := svc.IsAnInteractiveSession()
+	if err != nil {
+		return 0, err
+	}
+	if interactive {
+		return run(context.Background()), nil
+	}
+	// a service has no console and CTRL_BREAK_EVENT is delivered only to the
+	// processes sharing the console with the sender, so allocate one for the
+	// command to inherit
+	if r, _, err := procAllocConsole.Call(); r == 0 {
+		return 0, err
+	}
+	ts := &timeoutService{run: run}
+	if err := svc.Run(name, ts); err != nil {
+		return 0, err
+	}
+	return ts.exit, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/Songmu/timeout"
+)
+
+type stats struct {
+	Real     float64 `json:"real"`
+	User     float64 `json:"user"`
+	Sys      float64 `json:"sys"`
+	MaxRSS   int64   `json:"maxRss"`
+	ExitCode int     `json:"exitCode"`
+	TimedOut bool    `json:"timedOut"`
+	Killed   bool    `json:"killed"`
+}
+
+// printStats prints resource usages of the run like /usr/bin/time in the
+// format of "human" or "json"
+func printStats(w io.Writer, res *timeout.Result, format string) error {
+	st := &stats{
+		Real:     res.Elapsed().Seconds(),
+		User:     res.UserTime,
+		Sys:      res.SystemTime,
+		MaxRSS:   res.MaxRSS,
+		ExitCode: res.ExitCode,
+		TimedOut: res.TimedOut,
+		Killed:   res.Killed,
+	}
+	if format == "json" {
+		return json.NewEncoder(w).Encode(st)
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(tw, "real\t%.3fs\n", st.Real)
+	fmt.Fprintf(tw, "user\t%.3fs\n", st.User)
+	fmt.Fprintf(tw, "sys\t%.3fs\n", st.Sys)
+	if st.MaxRSS > 0 {
+		fmt.Fprintf(tw, "maxrss\t%dKB\n", st.MaxRSS/1024)
+	}
+	fmt.Fprintf(tw, "exit\t%d\n", st.ExitCode)
+	fmt.Fprintf(tw, "timed out\t%t\n", st.TimedOut)
+	return tw.Flush()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Songmu/timeout"
+)
+
+type status struct {
+	Command  string    `json:"command"`
+	ExitCode int       `json:"exitCode"`
+	Result   string    `json:"result"`
+	Hostname string    `json:"hostname"`
+	StartAt  time.Time `json:"startAt"`
+	EndAt    time.Time `json:"endAt"`
+	Elapsed  float64   `json:"elapsed"`
+	Signaled bool      `json:"signaled"`
+	TimedOut bool      `json:"timedOut"`
+	Killed   bool      `json:"killed"`
+	Trigger  string    `json:"trigger,omitempty"`
+
+	StdoutSHA256 string `json:"stdoutSha256,omitempty"`
+	StderrSHA256 string `json:"stderrSha256,omitempty"`
+}
+
+func newStatus(res *timeout.Result) *status {
+	return &status{
+		Command:  res.Command,
+		ExitCode: res.ExitCode,
+		Result:   res.Result,
+		Hostname: res.Hostname,
+		StartAt:  res.StartAt,
+		EndAt:    res.EndAt,
+		Elapsed:  res.Elapsed().Seconds(),
+		Signaled: res.Signaled,
+		TimedOut: res.TimedOut,
+		Killed:   res.Killed,
+		Trigger:  res.Trigger,
+
+		StdoutSHA256: res.StdoutSHA256,
+		StderrSHA256: res.StderrSHA256,
+	}
+}
+
+// writeStatusFile writes the status of the run to the file atomically, by
+// writing to a temporary file in the same directory and renaming it.
+func writeStatusFile(fname string, res *timeout.Result) error {
+	js, err := json.MarshalIndent(newStatus(res), "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(fname), "."+filepath.Base(fname)+".")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if _, err := f.Write(append(js, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fname)
+}
+
+// appendHistory appends the status of the run to the JSONL history file
+func appendHistory(fname string, res *timeout.Result) error {
+	js, err := json.Marshal(newStatus(res))
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	// write the line at once not to be interleaved with other invocations
+	if _, err := f.Write(append(js, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,331 @@
+package timeout
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durRe = regexp.MustCompile(`^([-0-9e.]+)([smhd])?$`)
+
+// ParseDuration parses a duration in the format of GNU timeout, a floating
+// point number with an optional suffix 's', 'm', 'h' or 'd', like "1.5m".
+// The format of time.ParseDuration like "1m30s" is also accepted.
+func ParseDuration(durStr string) (time.Duration, error) {
+	matches := durRe.FindStringSubmatch(durStr)
+	if len(matches) == 0 {
+		if d, err := time.ParseDuration(durStr); err == nil {
+			return d, nil
+		}
+		return 0, fmt.Errorf("duration format invalid: %s", durStr)
+	}
+
+	base, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time interval `%s`", durStr)
+	}
+	switch matches[2] {
+	case "", "s":
+	case "m":
+		base *= 60
+	case "h":
+		base *= 60 * 60
+	case "d":
+		base *= 60 * 60 * 24
+	default:
+		return 0, fmt.Errorf("invalid time interval `%s`", durStr)
+	}
+	return time.Duration(base * float64(time.Second)), nil
+}
+
+// Duration is a time.Duration implementing encoding.TextUnmarshaler to be
+// decoded from configuration files. See ParseDuration for the format.
+type Duration time.Duration
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (d *Duration) UnmarshalText(b []byte) error {
+	dur, err := ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(dur)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Signal is an os.Signal implementing encoding.TextUnmarshaler to be decoded
+// from configuration files. See ParseSignal for the format.
+// It is marshaled to the name of the signal, like "TERM".
+type Signal struct {
+	os.Signal
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (sig *Signal) UnmarshalText(b []byte) error {
+	s, err := ParseSignal(string(b))
+	if err != nil {
+		return err
+	}
+	sig.Signal = s
+	return nil
+}
+
+var signalNames = []string{"HUP", "INT", "QUIT", "KILL", "ALRM", "TERM", "USR1", "USR2"}
+
+// MarshalText implements encoding.TextMarshaler
+func (sig Signal) MarshalText() ([]byte, error) {
+	if sig.Signal == nil {
+		return []byte{}, nil
+	}
+	for _, name := range signalNames {
+		if s, err := ParseSignal(name); err == nil && s == sig.Signal {
+			return []byte(name), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signal: %s", sig.Signal)
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (ns *Namespace) UnmarshalText(b []byte) error {
+	n, err := ParseNamespace(string(b))
+	if err != nil {
+		return err
+	}
+	*ns = n
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (ns Namespace) MarshalText() ([]byte, error) {
+	return []byte(ns.String()), nil
+}
+
+// Config is the configuration of a Timeout, which can be decoded from
+// configuration files by DecodeConfig or by decoders honoring
+// encoding.TextUnmarshaler directly.
+type Config struct {
+	Command         []string  `json:"command" toml:"command" yaml:"command"`
+	Duration        Duration  `json:"duration" toml:"duration" yaml:"duration"`
+	KillAfter       Duration  `json:"kill_after" toml:"kill_after" yaml:"kill_after"`
+	Signal          Signal    `json:"signal" toml:"signal" yaml:"signal"`
+	Foreground      bool      `json:"foreground" toml:"foreground" yaml:"foreground"`
+	KillAfterCancel Duration  `json:"kill_after_cancel" toml:"kill_after_cancel" yaml:"kill_after_cancel"`
+	Unshare         Namespace `json:"unshare" toml:"unshare" yaml:"unshare"`
+	Checksum        bool      `json:"checksum" toml:"checksum" yaml:"checksum"`
+}
+
+// Timeout creates a Timeout from the Config
+func (c *Config) Timeout() (*Timeout, error) {
+	if len(c.Command) == 0 {
+		return nil, &ConfigError{Key: "command", Err: fmt.Errorf("no command specified")}
+	}
+	if c.Duration <= 0 {
+		return nil, &ConfigError{Key: "duration", Err: fmt.Errorf("duration must be positive")}
+	}
+	return &Timeout{
+		Cmd:             exec.Command(c.Command[0], c.Command[1:]...),
+		Duration:        time.Duration(c.Duration),
+		KillAfter:       time.Duration(c.KillAfter),
+		Signal:          c.Signal.Signal,
+		Foreground:      c.Foreground,
+		KillAfterCancel: time.Duration(c.KillAfterCancel),
+		Unshare:         c.Unshare,
+		Checksum:        c.Checksum,
+	}, nil
+}
+
+// ConfigError is the error of decoding a Config with the offending key
+type ConfigError struct {
+	Key string
+	Err error
+}
+
+func (err *ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Key, err.Err.Error())
+}
+
+// Unwrap returns the underlying error
+func (err *ConfigError) Unwrap() error {
+	return err.Err
+}
+
+// DecodeConfig decodes and validates a Config from the generic map, which
+// JSON, TOML and YAML decoders produce. Durations can be strings or numbers
+// in seconds, including json.Number of json.Decoder.UseNumber, and the
+// command can be a list of strings or a string.
+func DecodeConfig(m map[string]interface{}) (*Config, error) {
+	return decodeConfig("", m)
+}
+
+// DecodeConfigs decodes a named set of Configs, like the tables in TOML
+func DecodeConfigs(m map[string]interface{}) (map[string]*Config, error) {
+	configs := make(map[string]*Config, len(m))
+	for _, name := range sortedKeys(m) {
+		sub, ok := toMap(m[name])
+		if !ok {
+			return nil, &ConfigError{Key: name, Err: fmt.Errorf("must be a table but: %T", m[name])}
+		}
+		c, err := decodeConfig(name+".", sub)
+		if err != nil {
+			return nil, err
+		}
+		configs[name] = c
+	}
+	return configs, nil
+}
+
+func decodeConfig(prefix string, m map[string]interface{}) (*Config, error) {
+	c := &Config{}
+	for _, key := range sortedKeys(m) {
+		if err := c.set(key, m[key]); err != nil {
+			return nil, &ConfigError{Key: prefix + key, Err: err}
+		}
+	}
+	// validate
+	if _, err := c.Timeout(); err != nil {
+		if cerr, ok := err.(*ConfigError); ok {
+			cerr.Key = prefix + cerr.Key
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) set(key string, v interface{}) error {
+	switch key {
+	case "command":
+		return decodeCommand(&c.Command, v)
+	case "duration":
+		return decodeDuration(&c.Duration, v)
+	case "kill_after":
+		return decodeDuration(&c.KillAfter, v)
+	case "kill_after_cancel":
+		return decodeDuration(&c.KillAfterCancel, v)
+	case "signal":
+		switch vv := v.(type) {
+		case string:
+			return c.Signal.UnmarshalText([]byte(vv))
+		case int, int64, float64, json.Number:
+			return c.Signal.UnmarshalText([]byte(fmt.Sprint(vv)))
+		}
+	case "unshare":
+		switch vv := v.(type) {
+		case string:
+			return c.Unshare.UnmarshalText([]byte(vv))
+		case []interface{}:
+			strs, ok := toStrings(vv)
+			if !ok {
+				break
+			}
+			return c.Unshare.UnmarshalText([]byte(strings.Join(strs, ",")))
+		}
+	case "foreground":
+		return decodeBool(&c.Foreground, v)
+	case "checksum":
+		return decodeBool(&c.Checksum, v)
+	default:
+		return fmt.Errorf("unknown key")
+	}
+	return fmt.Errorf("invalid value: %v", v)
+}
+
+func decodeCommand(p *[]string, v interface{}) error {
+	switch vv := v.(type) {
+	case string:
+		*p = []string{vv}
+		return nil
+	case []interface{}:
+		if strs, ok := toStrings(vv); ok {
+			*p = strs
+			return nil
+		}
+	case []string:
+		*p = vv
+		return nil
+	}
+	return fmt.Errorf("must be a string or a list of strings but: %v", v)
+}
+
+func decodeDuration(p *Duration, v interface{}) error {
+	switch vv := v.(type) {
+	case string:
+		return p.UnmarshalText([]byte(vv))
+	case int:
+		*p = Duration(time.Duration(vv) * time.Second)
+	case int64:
+		*p = Duration(time.Duration(vv) * time.Second)
+	case float64:
+		*p = Duration(vv * float64(time.Second))
+	case json.Number:
+		f, err := vv.Float64()
+		if err != nil {
+			return fmt.Errorf("invalid duration: %v", v)
+		}
+		*p = Duration(f * float64(time.Second))
+	default:
+		return fmt.Errorf("invalid duration: %v", v)
+	}
+	if *p < 0 {
+		return fmt.Errorf("duration must not be negative: %v", v)
+	}
+	return nil
+}
+
+func decodeBool(p *bool, v interface{}) error {
+	b, ok := v.(bool)
+	if !ok {
+		return fmt.Errorf("must be a boolean but: %v", v)
+	}
+	*p = b
+	return nil
+}
+
+func toStrings(vs []interface{}) ([]string, bool) {
+	strs := make([]string, 0, len(vs))
+	for _, v := range vs {
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		strs = append(strs, s)
+	}
+	return strs, true
+}
+
+// toMap also accepts map[interface{}]interface{} which YAML decoders produce
+func toMap(v interface{}) (map[string]interface{}, bool) {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		return vv, true
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(vv))
+		for k, v := range vv {
+			ks, ok := k.(string)
+			if !ok {
+				return nil, false
+			}
+			m[ks] = v
+		}
+		return m, true
+	}
+	return nil, false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
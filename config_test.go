@@ -0,0 +1,176 @@
+package timeout
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	testCases := []struct {
+		input  string
+		expect time.Duration
+	}{
+		{"55", 55 * time.Second},
+		{"1.5m", 90 * time.Second},
+		{"1d", 24 * time.Hour},
+		{"1m30s", 90 * time.Second},
+	}
+	for _, tc := range testCases {
+		d, err := ParseDuration(tc.input)
+		if err != nil {
+			t.Errorf("%s: error should be nil but: %s", tc.input, err)
+		}
+		if d != tc.expect {
+			t.Errorf("%s: expected: %s, but: %s", tc.input, tc.expect, d)
+		}
+	}
+	if _, err := ParseDuration("1w"); err == nil {
+		t.Errorf("error should be occurred")
+	}
+}
+
+func TestConfig_unmarshalJSON(t *testing.T) {
+	var c Config
+	err := json.Unmarshal([]byte(`{
+  "command": ["sleep", "3"],
+  "duration": "1.5m",
+  "kill_after": "10s",
+  "signal": "INT",
+  "unshare": "net"
+}`), &c)
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	tio, err := c.Timeout()
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if tio.Duration != 90*time.Second || tio.KillAfter != 10*time.Second {
+		t.Errorf("invalid durations: %s, %s", tio.Duration, tio.KillAfter)
+	}
+	if tio.Signal != os.Interrupt {
+		t.Errorf("invalid signal: %v", tio.Signal)
+	}
+	if tio.Unshare != NamespaceNet {
+		t.Errorf("invalid unshare: %s", tio.Unshare)
+	}
+	if len(tio.Cmd.Args) != 2 || tio.Cmd.Args[1] != "3" {
+		t.Errorf("invalid command: %v", tio.Cmd.Args)
+	}
+}
+
+func TestConfig_marshalJSON(t *testing.T) {
+	c := Config{
+		Command:  []string{"sleep", "3"},
+		Duration: Duration(90 * time.Second),
+		Signal:   Signal{os.Kill},
+		Unshare:  NamespaceNet | NamespaceIPC,
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if !strings.Contains(string(b), `"signal":"KILL"`) {
+		t.Errorf("signal should be marshaled to its name: %s", b)
+	}
+	var got Config
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("round trip failed.\n   out: %#v\nexpect: %#v", got, c)
+	}
+}
+
+func TestDecodeConfig_jsonNumber(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`{"command": "sync.sh", "duration": 1.5, "signal": 9}`))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	c, err := DecodeConfig(m)
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if d := time.Duration(c.Duration); d != 1500*time.Millisecond {
+		t.Errorf("invalid duration: %s", d)
+	}
+	if c.Signal.Signal != os.Kill {
+		t.Errorf("invalid signal: %v", c.Signal.Signal)
+	}
+}
+
+func TestDecodeConfigs(t *testing.T) {
+	configs, err := DecodeConfigs(map[string]interface{}{
+		"backup": map[string]interface{}{
+			"command":    []interface{}{"backup.sh", "--all"},
+			"duration":   int64(300),
+			"kill_after": 1.5,
+		},
+		"sync": map[interface{}]interface{}{
+			"command":  "sync.sh",
+			"duration": "1h",
+		},
+	})
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if d := time.Duration(configs["backup"].Duration); d != 300*time.Second {
+		t.Errorf("invalid duration: %s", d)
+	}
+	if d := time.Duration(configs["backup"].KillAfter); d != 1500*time.Millisecond {
+		t.Errorf("invalid kill_after: %s", d)
+	}
+	if d := time.Duration(configs["sync"].Duration); d != time.Hour {
+		t.Errorf("invalid duration: %s", d)
+	}
+
+	testCases := []struct {
+		name string
+		m    map[string]interface{}
+		key  string
+	}{
+		{
+			name: "invalid duration",
+			m:    map[string]interface{}{"command": "true", "duration": "1w"},
+			key:  "job.duration",
+		},
+		{
+			name: "invalid signal",
+			m:    map[string]interface{}{"command": "true", "duration": 1, "signal": "FOO"},
+			key:  "job.signal",
+		},
+		{
+			name: "unknown key",
+			m:    map[string]interface{}{"command": "true", "duration": 1, "timeout": 1},
+			key:  "job.timeout",
+		},
+		{
+			name: "no command",
+			m:    map[string]interface{}{"duration": 1},
+			key:  "job.command",
+		},
+		{
+			name: "no duration",
+			m:    map[string]interface{}{"command": "true"},
+			key:  "job.duration",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodeConfigs(map[string]interface{}{"job": tc.m})
+			cerr, ok := err.(*ConfigError)
+			if !ok {
+				t.Fatalf("error should be *ConfigError but: %v", err)
+			}
+			if cerr.Key != tc.key {
+				t.Errorf("expected key: %s, but: %s", tc.key, cerr.Key)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package timeout
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+// DeadlineEnv is the environment variable to tell the deadline to the command
+// in RFC 3339 format. When it is set in the current process, which means the
+// process itself is run under a Timeout, Duration is clamped to the remaining
+// time, so that nested timeouts never exceed the budget of outer ones. The
+// command is not started and exits with 124 if the deadline has passed.
+const DeadlineEnv = "TIMEOUTS_DEADLINE"
+
+func deadlineFromEnv() (time.Time, bool) {
+	str := os.Getenv(DeadlineEnv)
+	if str == "" {
+		return time.Time{}, false
+	}
+	dl, err := time.Parse(time.RFC3339Nano, str)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return dl, true
+}
+
+var errDeadlineExceeded = errors.New("the deadline has already passed")
+
+// setupDeadline clamps the duration to the deadlines and tells the deadline to
+// the command. It returns an error when no time remains, not to start the
+// command only to terminate it at once.
+func (tio *Timeout) setupDeadline(now time.Time) error {
+	tio.duration = tio.Duration
+	if dl, ok := deadlineFromEnv(); ok {
+		if remain := dl.Sub(now); remain < tio.duration {
+			tio.duration = remain
+		}
+	}
+	// set by Fallback with a budget
+	if !tio.deadline.IsZero() {
+		if remain := tio.deadline.Sub(now); remain < tio.duration {
+			tio.duration = remain
+		}
+	}
+
+	if tio.duration <= 0 && tio.duration < tio.Duration {
+		return errDeadlineExceeded
+	}
+
+	cmd := tio.getCmd()
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	newEnv := make([]string, 0, len(env)+1)
+	for _, e := range env {
+		if !strings.HasPrefix(e, DeadlineEnv+"=") {
+			newEnv = append(newEnv, e)
+		}
+	}
+	cmd.Env = append(newEnv, DeadlineEnv+"="+now.Add(tio.duration).Format(time.RFC3339Nano))
+	return nil
+}
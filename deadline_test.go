@@ -0,0 +1,76 @@
+package timeout
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun_deadlineEnv(t *testing.T) {
+	t.Run("passed to the command", func(t *testing.T) {
+		tio := &Timeout{
+			Duration: 10 * time.Second,
+			Cmd:      exec.Command(shellcmd, shellflag, "echo $"+DeadlineEnv),
+		}
+		if isWin {
+			tio.Cmd = exec.Command(shellcmd, shellflag, "echo %"+DeadlineEnv+"%")
+		}
+		start := time.Now()
+		_, stdout, _, err := tio.Run()
+		if err != nil {
+			t.Fatalf("error should be nil but: %s", err)
+		}
+		dl, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(stdout))
+		if err != nil {
+			t.Fatalf("deadline should be passed but: %q", stdout)
+		}
+		if d := dl.Sub(start); d < 10*time.Second || d > 11*time.Second {
+			t.Errorf("invalid deadline: %s", dl)
+		}
+	})
+
+	t.Run("clamped by the outer deadline", func(t *testing.T) {
+		os.Setenv(DeadlineEnv, time.Now().Add(100*time.Millisecond).Format(time.RFC3339Nano))
+		defer os.Unsetenv(DeadlineEnv)
+
+		tio := &Timeout{
+			Duration: 10 * time.Second,
+			Cmd:      exec.Command(stubCmd, "-sleep", "3"),
+		}
+		start := time.Now()
+		exit := tio.RunSimple(false)
+		if exit != exitTimedOut {
+			t.Errorf("expected exitcode: %d, but: %d", exitTimedOut, exit)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("should be timed out by the outer deadline but took: %s", elapsed)
+		}
+	})
+	t.Run("outer deadline already passed", func(t *testing.T) {
+		os.Setenv(DeadlineEnv, time.Now().Add(-time.Second).Format(time.RFC3339Nano))
+		defer os.Unsetenv(DeadlineEnv)
+
+		tio := &Timeout{
+			Duration: 10 * time.Second,
+			Cmd:      exec.Command(stubCmd, "-trap", "SIGTERM", "-sleep", "3"),
+		}
+		start := time.Now()
+		_, err := tio.RunContext(context.Background())
+		e, ok := err.(*Error)
+		if !ok {
+			t.Fatalf("error should be *Error but: %#v", err)
+		}
+		if e.ExitCode != exitTimedOut {
+			t.Errorf("expected exitcode: %d, but: %d", exitTimedOut, e.ExitCode)
+		}
+		if tio.Cmd.Process != nil {
+			t.Errorf("command should not be started")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("should return at once but took: %s", elapsed)
+		}
+	})
+}
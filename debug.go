@@ -0,0 +1,31 @@
+package timeout
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// DebugEnv is the environment variable to enable tracing internal decisions
+// to stderr, when DebugWriter is not set
+const DebugEnv = "TIMEOUTS_DEBUG"
+
+func (tio *Timeout) debugWriter() io.Writer {
+	if tio.DebugWriter != nil {
+		return tio.DebugWriter
+	}
+	if v := os.Getenv(DebugEnv); v != "" && v != "0" {
+		return os.Stderr
+	}
+	return nil
+}
+
+func (tio *Timeout) debugf(format string, args ...interface{}) {
+	w := tio.debugWriter()
+	if w == nil {
+		return
+	}
+	fmt.Fprintf(w, "timeout: %s "+format+"\n",
+		append([]interface{}{time.Now().Format("15:04:05.000000")}, args...)...)
+}
@@ -0,0 +1,25 @@
+package timeout
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugWriter(t *testing.T) {
+	var buf bytes.Buffer
+	tio := &Timeout{
+		Duration:    100 * time.Millisecond,
+		Cmd:         exec.Command(stubCmd, "-sleep", "3"),
+		DebugWriter: &buf,
+	}
+	tio.RunSimple(false)
+	out := buf.String()
+	for _, expect := range []string{"started command", "timer armed", "timed out: wall clock time exceeded 100ms", "wait status received"} {
+		if !strings.Contains(out, expect) {
+			t.Errorf("trace should contain %q but:\n%s", expect, out)
+		}
+	}
+}
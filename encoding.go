@@ -0,0 +1,40 @@
+package timeout
+
+import (
+	"io"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+// decodeOutputs converts outputs of the command from OutputEncoding to UTF-8
+// before they reach the writers. The converters are kept in tio.closers and
+// closeOutputs has to be called after the command exits to flush incomplete
+// characters.
+func (tio *Timeout) decodeOutputs() {
+	if tio.OutputEncoding == nil || tio.OutputEncoding == encoding.Nop {
+		return
+	}
+	cmd := tio.getCmd()
+	// keep sharing a writer, so that exec.Cmd writes to it from one goroutine
+	sameWriter := cmd.Stdout != nil && cmd.Stdout == cmd.Stderr
+	for _, w := range []*io.Writer{&cmd.Stdout, &cmd.Stderr} {
+		if *w == nil {
+			continue
+		}
+		tw := transform.NewWriter(*w, tio.OutputEncoding.NewDecoder())
+		*w = tw
+		tio.closers = append(tio.closers, tw)
+	}
+	if sameWriter {
+		cmd.Stderr = cmd.Stdout
+		tio.closers = tio.closers[:1]
+	}
+}
+
+func (tio *Timeout) closeOutputs() {
+	for _, c := range tio.closers {
+		c.Close()
+	}
+	tio.closers = nil
+}
@@ -0,0 +1,101 @@
+package timeout
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os/exec"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestRun_outputEncoding(t *testing.T) {
+	if isWin {
+		t.Skip("skip on windows")
+	}
+	// "こんにちは" in Shift_JIS
+	sjis := `\202\261\202\361\202\311\202\277\202\315`
+	tio := &Timeout{
+		Duration:       10 * time.Second,
+		Cmd:            exec.Command(shellcmd, shellflag, "printf '"+sjis+"'"),
+		OutputEncoding: japanese.ShiftJIS,
+	}
+	_, stdout, _, err := tio.Run()
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if expect := "こんにちは"; stdout != expect {
+		t.Errorf("expected: %q, but: %q", expect, stdout)
+	}
+}
+
+func TestRunResult_outputEncodingChecksum(t *testing.T) {
+	if isWin {
+		t.Skip("skip on windows")
+	}
+	sjis := `\202\261\202\361\202\311\202\277\202\315`
+	tio := &Timeout{
+		Duration:       10 * time.Second,
+		Cmd:            exec.Command(shellcmd, shellflag, "printf '"+sjis+"'"),
+		OutputEncoding: japanese.ShiftJIS,
+		Checksum:       true,
+	}
+	res, err := tio.RunResult(context.Background())
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if expect := "こんにちは"; res.Stdout != expect {
+		t.Errorf("expected: %q, but: %q", expect, res.Stdout)
+	}
+	// the checksum is taken over the bytes the command wrote
+	sum := sha256.Sum256([]byte("\x82\xb1\x82\xf1\x82\xc9\x82\xbf\x82\xcd"))
+	if expect := hex.EncodeToString(sum[:]); res.StdoutSHA256 != expect {
+		t.Errorf("expected checksum: %s, but: %s", expect, res.StdoutSHA256)
+	}
+}
+
+func TestRunContext_outputEncodingWithoutOutputs(t *testing.T) {
+	tio := &Timeout{
+		Duration:       10 * time.Second,
+		Cmd:            exec.Command(stubCmd),
+		OutputEncoding: japanese.ShiftJIS,
+	}
+	st, err := tio.RunContext(context.Background())
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if st.GetExitCode() != 0 {
+		t.Errorf("expected exitcode: 0, but: %d", st.GetExitCode())
+	}
+
+	tio.Cmd = exec.Command(stubCmd)
+	ch, err := tio.RunCommand()
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	defer tio.Close()
+	if st := <-ch; st.GetExitCode() != 0 {
+		t.Errorf("expected exitcode: 0, but: %d", st.GetExitCode())
+	}
+}
+
+func TestRun_outputEncodingSharedWriter(t *testing.T) {
+	var buf bytes.Buffer
+	cmd := exec.Command(stubCmd)
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+	tio := &Timeout{
+		Duration:       10 * time.Second,
+		Cmd:            cmd,
+		OutputEncoding: japanese.ShiftJIS,
+	}
+	if _, err := tio.RunContext(context.Background()); err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if len(tio.closers) != 0 {
+		t.Errorf("converters should be closed")
+	}
+}
@@ -6,6 +6,7 @@ type ExitStatus struct {
 	Signaled bool
 	typ      exitType
 	killed   bool
+	trigger  string
 }
 
 // IsTimedOut returns the command timed out or not
@@ -18,6 +19,13 @@ func (ex *ExitStatus) IsCanceled() bool {
 	return ex.typ == exitTypeCanceled
 }
 
+// Trigger returns the reason reported by the Watcher which triggered the
+// termination of the command, such as "wall clock time exceeded 1m0s" for
+// Duration. It is empty when the command exited by itself or canceled.
+func (ex *ExitStatus) Trigger() string {
+	return ex.trigger
+}
+
 // IsKilled returns the command is killed or not
 func (ex *ExitStatus) IsKilled() bool {
 	return ex.killed
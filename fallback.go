@@ -0,0 +1,65 @@
+package timeout
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Fallback runs the Timeouts in order until one of them succeeds. The next
+// one is attempted when the previous one fails to start, times out or exits
+// with non-zero.
+type Fallback struct {
+	Timeouts []*Timeout
+	// Budget is the time for the whole chain, if positive. Duration of each
+	// Timeout is clamped to the remaining budget, and no more attempts are
+	// made after the budget runs out.
+	Budget time.Duration
+}
+
+// RunResult runs the chain and returns the Result of the last attempt, with
+// StartAt of the first attempt. When the chain has more than one Timeout, the
+// Results of all the attempts are set in Attempts without their outputs. The
+// returned error is the one of the last attempt.
+func (fb *Fallback) RunResult(ctx context.Context) (*Result, error) {
+	if len(fb.Timeouts) == 0 {
+		return nil, fmt.Errorf("no commands to run")
+	}
+	start := time.Now()
+	var (
+		attempts []*Result
+		res      *Result
+		err      error
+	)
+	for i, tio := range fb.Timeouts {
+		if fb.Budget > 0 {
+			deadline := start.Add(fb.Budget)
+			if i > 0 && !time.Now().Before(deadline) {
+				tio.debugf("budget %s ran out, giving up the fallback", fb.Budget)
+				break
+			}
+			tio.deadline = deadline
+		}
+		res, err = tio.RunResult(ctx)
+		// not to clamp the Timeout when it is run again outside the chain
+		tio.deadline = time.Time{}
+		attempt := *res
+		attempt.Output, attempt.Stdout, attempt.Stderr = "", "", ""
+		attempts = append(attempts, &attempt)
+		if err == nil && !res.TimedOut && res.ExitCode == 0 {
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if i < len(fb.Timeouts)-1 {
+			tio.debugf("%s, falling back to the next command", res.Result)
+		}
+	}
+	final := *res
+	final.StartAt = start
+	if len(fb.Timeouts) > 1 {
+		final.Attempts = attempts
+	}
+	return &final, err
+}
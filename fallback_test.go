@@ -0,0 +1,121 @@
+package timeout
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFallback(t *testing.T) {
+	fb := &Fallback{
+		Timeouts: []*Timeout{
+			{Duration: time.Second, Cmd: exec.Command(stubCmd, "-exit", "3")},
+			{Duration: 100 * time.Millisecond, Cmd: exec.Command(stubCmd, "-sleep", "3")},
+			{Duration: time.Second, Cmd: exec.Command("testdata/command-not-found")},
+			{Duration: time.Second, Cmd: exec.Command(stubCmd)},
+			{Duration: time.Second, Cmd: exec.Command(stubCmd, "-exit", "4")},
+		},
+	}
+	res, err := fb.RunResult(context.Background())
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if res.ExitCode != 0 || res.TimedOut {
+		t.Errorf("the last attempt should be succeeded: %+v", res)
+	}
+	if len(res.Attempts) != 4 {
+		t.Fatalf("expected attempts: 4, but: %d", len(res.Attempts))
+	}
+	if res.Attempts[0].ExitCode != 3 || !res.Attempts[1].TimedOut || res.Attempts[2].ExitStatus != nil {
+		t.Errorf("invalid attempts: %+v, %+v, %+v", res.Attempts[0], res.Attempts[1], res.Attempts[2])
+	}
+	if res.StartAt.After(res.Attempts[0].StartAt) {
+		t.Errorf("StartAt should be the one of the first attempt")
+	}
+}
+
+func TestFallback_budget(t *testing.T) {
+	fb := &Fallback{
+		Timeouts: []*Timeout{
+			{Duration: 10 * time.Second, Cmd: exec.Command(stubCmd, "-sleep", "3")},
+			{Duration: 10 * time.Second, Cmd: exec.Command(stubCmd, "-sleep", "3")},
+		},
+		Budget: 200 * time.Millisecond,
+	}
+	res, err := fb.RunResult(context.Background())
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if !res.TimedOut {
+		t.Errorf("should be timed out")
+	}
+	if elapsed := res.Elapsed(); elapsed > 2*time.Second {
+		t.Errorf("should be timed out within the budget but took: %s", elapsed)
+	}
+}
+
+func TestFallback_reuse(t *testing.T) {
+	tio := &Timeout{Duration: 10 * time.Second, Cmd: exec.Command(stubCmd, "-sleep", "3")}
+	fb := &Fallback{
+		Timeouts: []*Timeout{tio},
+		Budget:   100 * time.Millisecond,
+	}
+	if _, err := fb.RunResult(context.Background()); err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+
+	tio.Cmd = exec.Command(stubCmd, "-sleep", "1")
+	res, err := tio.RunResult(context.Background())
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if res.TimedOut {
+		t.Errorf("the budget of the chain should not be applied outside it")
+	}
+}
+
+func TestFallback_attempts(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cmds     []string
+		attempts int
+		output   string
+	}{
+		{
+			name:   "single",
+			cmds:   []string{"echo 1"},
+			output: "1",
+		},
+		{
+			name:     "fallback",
+			cmds:     []string{"echo 1 && exit 1", "echo 2"},
+			attempts: 2,
+			output:   "2",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fb := &Fallback{}
+			for _, c := range tc.cmds {
+				fb.Timeouts = append(fb.Timeouts, &Timeout{Duration: time.Second, Cmd: exec.Command(shellcmd, shellflag, c)})
+			}
+			res, err := fb.RunResult(context.Background())
+			if err != nil {
+				t.Fatalf("error should be nil but: %s", err)
+			}
+			if len(res.Attempts) != tc.attempts {
+				t.Errorf("expected attempts: %d, but: %d", tc.attempts, len(res.Attempts))
+			}
+			for _, a := range res.Attempts {
+				if a.Output != "" || a.Stdout != "" || a.Stderr != "" {
+					t.Errorf("outputs of the attempts should be stripped: %+v", a)
+				}
+			}
+			if strings.TrimSpace(res.Output) != tc.output {
+				t.Errorf("expected output: %q, but: %q", tc.output, res.Output)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package timeout
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// AbandonedError is returned by Func when the function does not return even
+// after kill-after. The goroutine running the function is left behind and
+// Stack holds its stack trace at the time it was abandoned.
+type AbandonedError struct {
+	Stack string
+}
+
+func (err *AbandonedError) Error() string {
+	return "function abandoned after kill-after, goroutine leaked:\n" + err.Stack
+}
+
+// Func runs f with the same two-stage model as Timeout. The context passed to
+// f has the deadline d (and is canceled when ctx is done), and f is abandoned
+// if it does not return within killAfter after that. A killAfter of zero never
+// abandons f on timeout. ExitStatus.Code is 0 when f returns nil, 1 when f
+// returns an error and 137 when f is abandoned.
+func Func(ctx context.Context, d, killAfter time.Duration, f func(context.Context) error) (*ExitStatus, error) {
+	fctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	gidCh := make(chan uint64, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		gidCh <- currentGoroutineID()
+		errCh <- f(fctx)
+	}()
+	gid := <-gidCh
+
+	ex := &ExitStatus{}
+	returned := func(err error) (*ExitStatus, error) {
+		if err != nil {
+			ex.Code = 1
+		}
+		return ex, err
+	}
+	select {
+	case err := <-errCh:
+		return returned(err)
+	case <-fctx.Done():
+	}
+
+	ex.typ = exitTypeTimedOut
+	grace := killAfter
+	if ctx.Err() != nil {
+		ex.typ = exitTypeCanceled
+		grace = defaultKillAfterCancel
+	}
+	if grace <= 0 {
+		return returned(<-errCh)
+	}
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case err := <-errCh:
+		return returned(err)
+	case <-timer.C:
+		ex.Code = exitKilled
+		ex.killed = true
+		if ex.typ != exitTypeCanceled {
+			ex.typ = exitTypeKilled
+		}
+		return ex, &AbandonedError{Stack: goroutineStack(gid)}
+	}
+}
+
+func currentGoroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	// "goroutine 123 [running]:..."
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i >= 0 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
+
+func goroutineStack(id uint64) string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	prefix := []byte(fmt.Sprintf("goroutine %d ", id))
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(stack, prefix) {
+			return string(stack)
+		}
+	}
+	return ""
+}
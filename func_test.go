@@ -0,0 +1,101 @@
+package timeout
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFunc(t *testing.T) {
+	errFoo := errors.New("foo")
+	block := make(chan struct{})
+	defer close(block)
+
+	testCases := []struct {
+		name      string
+		killAfter time.Duration
+		f         func(context.Context) error
+		err       error
+		code      int
+		timedOut  bool
+		killed    bool
+	}{
+		{
+			name: "success",
+			f:    func(context.Context) error { return nil },
+		},
+		{
+			name: "error",
+			f:    func(context.Context) error { return errFoo },
+			err:  errFoo,
+			code: 1,
+		},
+		{
+			name: "timed out",
+			f: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			err:      context.DeadlineExceeded,
+			code:     1,
+			timedOut: true,
+		},
+		{
+			name:      "abandoned",
+			killAfter: 100 * time.Millisecond,
+			f: func(context.Context) error {
+				<-block
+				return nil
+			},
+			code:     exitKilled,
+			timedOut: true,
+			killed:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			st, err := Func(context.Background(), 100*time.Millisecond, tc.killAfter, tc.f)
+			if tc.killed {
+				aerr, ok := err.(*AbandonedError)
+				if !ok {
+					t.Fatalf("error should be *AbandonedError but: %v", err)
+				}
+				if !strings.Contains(aerr.Stack, "TestFunc") {
+					t.Errorf("stack of the abandoned goroutine should be reported but: %s", aerr.Stack)
+				}
+			} else if err != tc.err {
+				t.Errorf("expected error: %v, but: %v", tc.err, err)
+			}
+			if st.Code != tc.code {
+				t.Errorf("expected code: %d, but: %d", tc.code, st.Code)
+			}
+			if st.IsTimedOut() != tc.timedOut {
+				t.Errorf("expected timed out: %t, but: %t", tc.timedOut, st.IsTimedOut())
+			}
+			if st.IsKilled() != tc.killed {
+				t.Errorf("expected killed: %t, but: %t", tc.killed, st.IsKilled())
+			}
+		})
+	}
+}
+
+func TestFunc_cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	st, err := Func(ctx, 3*time.Second, 0, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != context.Canceled {
+		t.Errorf("expected error: %v, but: %v", context.Canceled, err)
+	}
+	if !st.IsCanceled() {
+		t.Errorf("should be canceled")
+	}
+}
@@ -5,4 +5,6 @@ go 1.12
 require (
 	github.com/Songmu/wrapcommander v0.1.0
 	github.com/pborman/getopt v0.0.0-20190409184431-ee0cd42419d3
+	golang.org/x/sys v0.0.0-20190422165155-953cdadca894
+	golang.org/x/text v0.3.2
 )
@@ -0,0 +1,58 @@
+package timeout
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Namespace is a set of Linux namespaces to be unshared for the command
+type Namespace uint
+
+// namespaces which can be unshared
+const (
+	NamespaceMount Namespace = 1 << iota
+	NamespaceNet
+	NamespaceIPC
+)
+
+var namespaceNames = []struct {
+	ns   Namespace
+	name string
+}{
+	{NamespaceMount, "mount"},
+	{NamespaceNet, "net"},
+	{NamespaceIPC, "ipc"},
+}
+
+func (ns Namespace) String() string {
+	var names []string
+	for _, n := range namespaceNames {
+		if ns&n.ns != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseNamespace parses a comma separated list of namespaces like "net,mount"
+func ParseNamespace(str string) (Namespace, error) {
+	var ns Namespace
+	for _, s := range strings.Split(str, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" {
+			continue
+		}
+		found := false
+		for _, n := range namespaceNames {
+			if s == n.name {
+				ns |= n.ns
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("%s: invalid namespace", s)
+		}
+	}
+	return ns, nil
+}
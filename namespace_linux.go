@@ -0,0 +1,73 @@
+package timeout
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const capSysAdmin = 21
+
+func (ns Namespace) unshareflags() uintptr {
+	var flags uintptr
+	if ns&NamespaceMount != 0 {
+		flags |= syscall.CLONE_NEWNS
+	}
+	if ns&NamespaceNet != 0 {
+		flags |= syscall.CLONE_NEWNET
+	}
+	if ns&NamespaceIPC != 0 {
+		flags |= syscall.CLONE_NEWIPC
+	}
+	return flags
+}
+
+// the namespaces are unshared by Unshareflags instead of Cloneflags, with
+// which the runtime remounts / as private in the new mount namespace like
+// unshare(1), not to propagate the mounts of the command to the host
+func (tio *Timeout) setupNamespace() error {
+	if tio.Unshare == 0 {
+		return nil
+	}
+	cmd := tio.getCmd()
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Unshareflags |= tio.Unshare.unshareflags()
+	return nil
+}
+
+// validateNamespace checks the process has CAP_SYS_ADMIN which is required
+// to unshare namespaces
+func (tio *Timeout) validateNamespace() error {
+	if tio.Unshare == 0 {
+		return nil
+	}
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+		caps, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+		if err != nil {
+			return err
+		}
+		if caps&(1<<capSysAdmin) == 0 {
+			return fmt.Errorf("unsharing namespaces (%s) requires CAP_SYS_ADMIN", tio.Unshare)
+		}
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("failed to detect the capabilities of the process")
+}
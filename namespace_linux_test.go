@@ -0,0 +1,64 @@
+package timeout
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun_unshareNet(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("unsharing namespaces requires root")
+	}
+	self, err := os.Readlink("/proc/self/ns/net")
+	if err != nil {
+		t.Skipf("network namespace is not available: %s", err)
+	}
+	tio := &Timeout{
+		Duration: 3 * time.Second,
+		Cmd:      exec.Command("readlink", "/proc/self/ns/net"),
+		Unshare:  NamespaceNet,
+	}
+	st, stdout, _, err := tio.Run()
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if st.GetExitCode() != 0 {
+		t.Fatalf("expected exitcode: 0, but: %d", st.GetExitCode())
+	}
+	if child := strings.TrimSpace(stdout); child == self {
+		t.Errorf("network namespace should be unshared but: %s", child)
+	}
+}
+
+func TestRun_unshareMount(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("unsharing namespaces requires root")
+	}
+	self, err := os.Readlink("/proc/self/ns/mnt")
+	if err != nil {
+		t.Skipf("mount namespace is not available: %s", err)
+	}
+	tio := &Timeout{
+		Duration: 3 * time.Second,
+		// the root mount should not be a shared one (no "shared:N" field)
+		Cmd:     exec.Command("sh", "-c", "readlink /proc/self/ns/mnt && awk '$5 == \"/\"' /proc/self/mountinfo"),
+		Unshare: NamespaceMount | NamespaceNet,
+	}
+	st, stdout, _, err := tio.Run()
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if st.GetExitCode() != 0 {
+		t.Fatalf("expected exitcode: 0, but: %d", st.GetExitCode())
+	}
+	lines := strings.SplitN(strings.TrimSpace(stdout), "\n", 2)
+	if lines[0] == self {
+		t.Errorf("mount namespace should be unshared but: %s", lines[0])
+	}
+	if len(lines) < 2 || strings.Contains(lines[1], "shared:") {
+		t.Errorf("root should be mounted as private but: %q", stdout)
+	}
+}
@@ -0,0 +1,16 @@
+// +build !linux
+
+package timeout
+
+import "fmt"
+
+func (tio *Timeout) validateNamespace() error {
+	if tio.Unshare == 0 {
+		return nil
+	}
+	return fmt.Errorf("unsharing namespaces (%s) is not supported on this platform", tio.Unshare)
+}
+
+func (tio *Timeout) setupNamespace() error {
+	return tio.validateNamespace()
+}
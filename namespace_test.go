@@ -0,0 +1,32 @@
+package timeout
+
+import "testing"
+
+func TestParseNamespace(t *testing.T) {
+	testCases := []struct {
+		input  string
+		expect Namespace
+		err    bool
+	}{
+		{input: "", expect: 0},
+		{input: "net", expect: NamespaceNet},
+		{input: "net,mount", expect: NamespaceNet | NamespaceMount},
+		{input: "IPC, net", expect: NamespaceIPC | NamespaceNet},
+		{input: "pid", err: true},
+	}
+	for _, tc := range testCases {
+		ns, err := ParseNamespace(tc.input)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%q: error should be occurred", tc.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: error should be nil but: %s", tc.input, err)
+		}
+		if ns != tc.expect {
+			t.Errorf("%q: expected: %s, but: %s", tc.input, tc.expect, ns)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package timeout
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// limit of each output kept in the Result
+const resultOutputLimit = 64 * 1024
+
+// Result is the report of a command run. Its JSON representation follows the
+// report of horenso (github.com/Songmu/horenso), so that its reporters can be
+// reused.
+type Result struct {
+	Command     string      `json:"command"`
+	CommandArgs []string    `json:"commandArgs"`
+	Output      string      `json:"output"`
+	Stdout      string      `json:"stdout"`
+	Stderr      string      `json:"stderr"`
+	ExitCode    int         `json:"exitCode"`
+	Result      string      `json:"result"`
+	Hostname    string      `json:"hostname"`
+	Pid         int         `json:"pid,omitempty"`
+	StartAt     time.Time   `json:"startAt"`
+	EndAt       time.Time   `json:"endAt"`
+	Signaled    bool        `json:"signaled"`
+	TimedOut    bool        `json:"timedOut"`
+	Killed      bool        `json:"killed"`
+	Trigger     string      `json:"trigger,omitempty"`
+	ExitStatus  *ExitStatus `json:"-"`
+
+	// CPU times in seconds and the maximum resident set size in bytes (0 if unavailable)
+	UserTime   float64 `json:"userTime"`
+	SystemTime float64 `json:"systemTime"`
+	MaxRSS     int64   `json:"maxRss,omitempty"`
+
+	// hex encoded SHA-256 checksums of the whole outputs as written by the
+	// command, set when Timeout.Checksum is true
+	StdoutSHA256 string `json:"stdoutSha256,omitempty"`
+	StderrSHA256 string `json:"stderrSha256,omitempty"`
+
+	// results of all the attempts in order without their outputs, set by
+	// Fallback with more than one command
+	Attempts []*Result `json:"attempts,omitempty"`
+}
+
+// Elapsed returns the wall time of the run
+func (res *Result) Elapsed() time.Duration {
+	return res.EndAt.Sub(res.StartAt)
+}
+
+// RunResult runs the command like RunContext and reports the run as a Result.
+// Outputs of the command are kept in the Result up to the last 64KiB of each,
+// in addition to being written to Cmd.Stdout and Cmd.Stderr if they are set,
+// unless NoCapture is true.
+func (tio *Timeout) RunResult(ctx context.Context) (*Result, error) {
+	cmd := tio.getCmd()
+	res := &Result{
+		Command:     shellJoin(cmd.Args),
+		CommandArgs: cmd.Args,
+	}
+	res.Hostname, _ = os.Hostname()
+
+	var (
+		outBuffer = &tailBuffer{limit: resultOutputLimit}
+		errBuffer = &tailBuffer{limit: resultOutputLimit}
+		allBuffer = &tailBuffer{limit: resultOutputLimit}
+	)
+	var outWriters, errWriters []io.Writer
+	if !tio.NoCapture {
+		outWriters = append(outWriters, outBuffer, allBuffer)
+		errWriters = append(errWriters, errBuffer, allBuffer)
+	}
+	var outHash, errHash hash.Hash
+	if tio.Checksum {
+		outHash, errHash = sha256.New(), sha256.New()
+		tio.rawStdout, tio.rawStderr = outHash, errHash
+		defer func() {
+			tio.rawStdout, tio.rawStderr = nil, nil
+		}()
+	}
+	// wrapping the outputs makes exec.Cmd pass pipes to the command instead
+	// of the writers themselves, so leave them as they are if possible
+	stdout, stderr := cmd.Stdout, cmd.Stderr
+	if len(outWriters) > 0 {
+		out, errw := lockShared(stdout, stderr)
+		cmd.Stdout = teeWriter(out, outWriters...)
+		cmd.Stderr = teeWriter(errw, errWriters...)
+	}
+	defer func() {
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+	}()
+
+	res.StartAt = time.Now()
+	ex, err := tio.RunContext(ctx)
+	res.EndAt = time.Now()
+	res.Output = allBuffer.String()
+	res.Stdout = outBuffer.String()
+	res.Stderr = errBuffer.String()
+	if tio.Checksum {
+		res.StdoutSHA256 = hex.EncodeToString(outHash.Sum(nil))
+		res.StderrSHA256 = hex.EncodeToString(errHash.Sum(nil))
+	}
+	if err != nil {
+		res.ExitCode = getExitCodeFromErr(err)
+		res.Result = fmt.Sprintf("failed to execute command: %s", err)
+		return res, err
+	}
+	res.ExitStatus = ex
+	res.Pid = cmd.Process.Pid
+	if ps := cmd.ProcessState; ps != nil {
+		res.UserTime = ps.UserTime().Seconds()
+		res.SystemTime = ps.SystemTime().Seconds()
+		res.MaxRSS = maxRSS(ps)
+	}
+	res.ExitCode = ex.GetChildExitCode()
+	res.Signaled = ex.Signaled
+	res.TimedOut = ex.IsTimedOut()
+	res.Killed = ex.IsKilled()
+	res.Trigger = ex.Trigger()
+	switch {
+	case ex.Trigger() != "" && ex.IsKilled():
+		res.Result = fmt.Sprintf("command killed after timed out (%s) with code: %d", ex.Trigger(), res.ExitCode)
+	case ex.Trigger() != "":
+		res.Result = fmt.Sprintf("command timed out (%s) with code: %d", ex.Trigger(), res.ExitCode)
+	case ex.IsKilled():
+		res.Result = fmt.Sprintf("command killed after timed out with code: %d", res.ExitCode)
+	case ex.IsTimedOut():
+		res.Result = fmt.Sprintf("command timed out with code: %d", res.ExitCode)
+	case ex.IsCanceled():
+		res.Result = fmt.Sprintf("command canceled with code: %d", res.ExitCode)
+	default:
+		res.Result = fmt.Sprintf("command exited with code: %d", res.ExitCode)
+	}
+	return res, nil
+}
+
+func teeWriter(w io.Writer, ws ...io.Writer) io.Writer {
+	if w != nil {
+		ws = append([]io.Writer{w}, ws...)
+	}
+	return io.MultiWriter(ws...)
+}
+
+// lockShared wraps the writer in a lockedWriter when it is shared by stdout
+// and stderr. exec.Cmd writes to such a writer from one goroutine, but no
+// longer does once they are wrapped separately.
+func lockShared(stdout, stderr io.Writer) (io.Writer, io.Writer) {
+	if stdout == nil || stdout != stderr {
+		return stdout, stderr
+	}
+	lw := &lockedWriter{w: stdout}
+	return lw, lw
+}
+
+// lockedWriter serializes the writes to w
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	mu    sync.Mutex
+	buf   []byte
+	limit int
+}
+
+func (tb *tailBuffer) Write(p []byte) (int, error) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	n := len(p)
+	if len(p) >= tb.limit {
+		tb.buf = append(tb.buf[:0], p[len(p)-tb.limit:]...)
+		return n, nil
+	}
+	if over := len(tb.buf) + len(p) - tb.limit; over > 0 {
+		tb.buf = append(tb.buf[:0], tb.buf[over:]...)
+	}
+	tb.buf = append(tb.buf, p...)
+	return n, nil
+}
+
+func (tb *tailBuffer) String() string {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	return string(tb.buf)
+}
+
+func shellJoin(args []string) string {
+	var buf bytes.Buffer
+	for i, arg := range args {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(shellQuote(arg))
+	}
+	return buf.String()
+}
+
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			r == '-' || r == '_' || r == '.' || r == '/' || r == '=' || r == ':' || r == ',') {
+			return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+		}
+	}
+	return s
+}
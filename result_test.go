@@ -0,0 +1,160 @@
+package timeout
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunResult(t *testing.T) {
+	var out bytes.Buffer
+	cmd := exec.Command(shellcmd, shellflag, "echo 1")
+	cmd.Stdout = &out
+	tio := &Timeout{
+		Duration: 10 * time.Second,
+		Cmd:      cmd,
+	}
+	res, err := tio.RunResult(context.Background())
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if strings.TrimSpace(res.Stdout) != "1" || strings.TrimSpace(res.Output) != "1" {
+		t.Errorf("output should be captured but: %q, %q", res.Stdout, res.Output)
+	}
+	if strings.TrimSpace(out.String()) != "1" {
+		t.Errorf("output should be also written to Cmd.Stdout but: %q", out.String())
+	}
+	if res.ExitCode != 0 || res.TimedOut {
+		t.Errorf("invalid result: %+v", res)
+	}
+	if res.Elapsed() <= 0 {
+		t.Errorf("elapsed should be positive but: %s", res.Elapsed())
+	}
+	if cmd.Stdout != &out {
+		t.Errorf("Cmd.Stdout should be restored")
+	}
+}
+
+func TestRunResult_sharedOutput(t *testing.T) {
+	var out bytes.Buffer
+	cmd := bothOutputsCmd()
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	tio := &Timeout{
+		Duration: 10 * time.Second,
+		Cmd:      cmd,
+	}
+	res, err := tio.RunResult(context.Background())
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if strings.Count(out.String(), "out") != 5 || strings.Count(out.String(), "err") != 5 {
+		t.Errorf("both outputs should be written to the shared writer but: %q", out.String())
+	}
+	if len(res.Output) != out.Len() {
+		t.Errorf("output should be captured. out: %q, expect: %q", res.Output, out.String())
+	}
+}
+
+func TestRunResult_timedOut(t *testing.T) {
+	tio := &Timeout{
+		Duration: 100 * time.Millisecond,
+		Cmd:      exec.Command(stubCmd, "-sleep", "3"),
+	}
+	res, err := tio.RunResult(context.Background())
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if !res.TimedOut {
+		t.Errorf("should be timed out")
+	}
+	if res.ExitStatus.GetExitCode() != exitTimedOut {
+		t.Errorf("expected exitcode: %d, but: %d", exitTimedOut, res.ExitStatus.GetExitCode())
+	}
+	// Duration is run as a watcher and recorded as the trigger
+	if expect := "wall clock time exceeded 100ms"; res.Trigger != expect {
+		t.Errorf("expected trigger: %q, but: %q", expect, res.Trigger)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	tb := &tailBuffer{limit: 5}
+	tb.Write([]byte("abc"))
+	tb.Write([]byte("def"))
+	if tb.String() != "bcdef" {
+		t.Errorf("expected: %q, but: %q", "bcdef", tb.String())
+	}
+	tb.Write([]byte("0123456789"))
+	if tb.String() != "56789" {
+		t.Errorf("expected: %q, but: %q", "56789", tb.String())
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	out := shellJoin([]string{"sh", "-c", "echo 'a' b"})
+	expect := `sh -c 'echo '\''a'\'' b'`
+	if out != expect {
+		t.Errorf("expected: %s, but: %s", expect, out)
+	}
+}
+
+func TestRunResult_checksum(t *testing.T) {
+	tio := &Timeout{
+		Duration: 10 * time.Second,
+		Cmd:      exec.Command(shellcmd, shellflag, "echo 1"),
+		Checksum: true,
+	}
+	res, err := tio.RunResult(context.Background())
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	sum := sha256.Sum256([]byte(res.Stdout))
+	if expect := hex.EncodeToString(sum[:]); res.StdoutSHA256 != expect {
+		t.Errorf("expected checksum: %s, but: %s", expect, res.StdoutSHA256)
+	}
+	// SHA-256 of empty input
+	if expect := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"; res.StderrSHA256 != expect {
+		t.Errorf("expected checksum: %s, but: %s", expect, res.StderrSHA256)
+	}
+}
+
+func TestRunResult_noCapture(t *testing.T) {
+	if isWin {
+		t.Skip("skip on windows")
+	}
+	devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devnull.Close()
+
+	// the background process keeps holding the output, which blocks Wait
+	// if the output is a pipe
+	cmd := exec.Command(shellcmd, shellflag, "sleep 3 & sleep 3")
+	cmd.Stdout = devnull
+	tio := &Timeout{
+		Duration:   100 * time.Millisecond,
+		Cmd:        cmd,
+		Foreground: true,
+		NoCapture:  true,
+	}
+	res, err := tio.RunResult(context.Background())
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if !res.TimedOut {
+		t.Errorf("should be timed out")
+	}
+	if elapsed := res.Elapsed(); elapsed > 2*time.Second {
+		t.Errorf("the file should be passed to the command as is but took: %s", elapsed)
+	}
+	if cmd.Stdout != devnull {
+		t.Errorf("Cmd.Stdout should be kept")
+	}
+}
@@ -0,0 +1,31 @@
+// +build js
+
+package timeout
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// ParseSignal parses a signal name like "HUP" or a number. It returns nil
+// signal for an empty string, which means the default signal.
+func ParseSignal(sigStr string) (os.Signal, error) {
+	switch strings.TrimPrefix(strings.ToUpper(sigStr), "SIG") {
+	case "":
+		return nil, nil
+	case "INT", "2":
+		return os.Interrupt, nil
+	case "QUIT", "3":
+		return syscall.SIGQUIT, nil
+	case "KILL", "9":
+		return os.Kill, nil
+	case "TERM", "15":
+		return syscall.SIGTERM, nil
+	case "HUP", "1", "ALRM", "14", "USR1", "USR2":
+		return nil, syscall.ENOSYS
+	default:
+		return nil, fmt.Errorf("%s: invalid signal", sigStr)
+	}
+}
@@ -1,6 +1,6 @@
-// +build !windows
+// +build !windows,!js
 
-package main
+package timeout
 
 import (
 	"fmt"
@@ -9,8 +9,10 @@ import (
 	"syscall"
 )
 
-func parseSignal(sigStr string) (os.Signal, error) {
-	switch strings.ToUpper(sigStr) {
+// ParseSignal parses a signal name like "HUP" or a number. It returns nil
+// signal for an empty string, which means the default signal.
+func ParseSignal(sigStr string) (os.Signal, error) {
+	switch strings.TrimPrefix(strings.ToUpper(sigStr), "SIG") {
 	case "":
 		return nil, nil
 	case "HUP", "1":
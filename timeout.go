@@ -4,13 +4,16 @@ package timeout
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"syscall"
 	"time"
 
 	"github.com/Songmu/wrapcommander"
+	"golang.org/x/text/encoding"
 )
 
 // exit statuses are same with GNU timeout
@@ -21,9 +24,19 @@ const (
 	exitKilled     = 137
 )
 
+// the grace period before killing the command when the context is done and
+// KillAfterCancel is not set
+const defaultKillAfterCancel = 3 * time.Second
+
 // overwritten with syscall.SIGTERM on unix environment (see timeout_unix.go)
 var defaultSignal = os.Interrupt
 
+// overwritten with false on environments which cannot control processes (see timeout_js.go)
+var processSupported = true
+
+// ErrUnsupported is the error returned when invoking commands is not supported on the platform
+var ErrUnsupported = errors.New("process control is not supported on this platform")
+
 // Error is error of timeout
 type Error struct {
 	ExitCode int
@@ -34,6 +47,11 @@ func (err *Error) Error() string {
 	return fmt.Sprintf("exit code: %d, %s", err.ExitCode, err.Err.Error())
 }
 
+// Unwrap returns the underlying error
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
 // Timeout is main struct of timeout package
 type Timeout struct {
 	Duration   time.Duration
@@ -43,6 +61,43 @@ type Timeout struct {
 	Cmd        *exec.Cmd
 
 	KillAfterCancel time.Duration
+
+	// Unshare is the set of namespaces to be unshared for the command (Linux only)
+	Unshare Namespace
+
+	// Checksum makes RunResult compute SHA-256 checksums of the whole outputs,
+	// over the bytes the command wrote before OutputEncoding converts them
+	Checksum bool
+	// NoCapture makes RunResult not keep the outputs in the Result
+	NoCapture bool
+
+	// TeeStdout and TeeStderr receive a copy of the outputs of the command,
+	// whichever Run method is used
+	TeeStdout io.Writer
+	TeeStderr io.Writer
+
+	// Watchers trigger the termination of the command on conditions other
+	// than Duration, such as idle output or memory usage
+	Watchers []Watcher
+
+	// OutputEncoding is the encoding of the outputs of the command, such as
+	// japanese.ShiftJIS. The outputs are converted to UTF-8 before they reach
+	// Cmd.Stdout, Cmd.Stderr, the tee writers and the Result.
+	OutputEncoding encoding.Encoding
+
+	// DebugWriter receives traces of internal decisions, such as arming
+	// timers and sending signals. TIMEOUTS_DEBUG=1 traces to stderr when it is nil.
+	DebugWriter io.Writer
+
+	// Duration clamped to the deadline of the outer timeout (see DeadlineEnv)
+	duration time.Duration
+	deadline time.Time
+	// converters of the outputs to be closed after the command exits
+	closers []io.Closer
+	state   runState
+	target  *WatchTarget
+	// receive the outputs as written by the command, before decoded
+	rawStdout, rawStderr io.Writer
 }
 
 func (tio *Timeout) signal() os.Signal {
@@ -54,6 +109,7 @@ func (tio *Timeout) signal() os.Signal {
 
 // Run is synchronous interface of executing command and returning information
 func (tio *Timeout) Run() (*ExitStatus, string, string, error) {
+	defer tio.Close()
 	cmd := tio.getCmd()
 	var outBuffer, errBuffer bytes.Buffer
 	cmd.Stdout = &outBuffer
@@ -70,6 +126,7 @@ func (tio *Timeout) Run() (*ExitStatus, string, string, error) {
 
 // RunSimple executes command and only returns integer as exit code. It is mainly for go-timeout command
 func (tio *Timeout) RunSimple(preserveStatus bool) int {
+	defer tio.Close()
 	cmd := tio.getCmd()
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -99,6 +156,7 @@ func getExitCodeFromErr(err error) int {
 
 // RunContext runs command with context
 func (tio *Timeout) RunContext(ctx context.Context) (*ExitStatus, error) {
+	defer tio.Close()
 	if err := tio.start(); err != nil {
 		return nil, err
 	}
@@ -111,82 +169,171 @@ func (tio *Timeout) RunCommand() (<-chan *ExitStatus, error) {
 		return nil, err
 	}
 
-	exitChan := make(chan *ExitStatus)
-	go func() {
+	// buffered not to leak the goroutine even if nobody receives
+	exitChan := make(chan *ExitStatus, 1)
+	tio.state.goFunc(func() {
 		exitChan <- tio.wait(context.Background())
-	}()
+	})
 	return exitChan, nil
 }
 
 func (tio *Timeout) start() error {
-	if err := tio.getCmd().Start(); err != nil {
+	if !processSupported {
+		return &Error{
+			ExitCode: exitUnknownErr,
+			Err:      ErrUnsupported,
+		}
+	}
+	if err := tio.setupNamespace(); err != nil {
+		return &Error{
+			ExitCode: exitUnknownErr,
+			Err:      err,
+		}
+	}
+	if err := tio.validateWatchers(); err != nil {
+		return &Error{
+			ExitCode: exitUnknownErr,
+			Err:      err,
+		}
+	}
+	tio.state.reset()
+	if err := tio.setupDeadline(time.Now()); err != nil {
+		tio.debugf("%s, not starting the command", err)
+		return &Error{
+			ExitCode: exitTimedOut,
+			Err:      err,
+		}
+	}
+	cmd := tio.getCmd()
+	// the outputs are copied from separate goroutines once they are teed
+	teeOut, teeErr := lockShared(tio.TeeStdout, tio.TeeStderr)
+	if teeOut != nil || teeErr != nil {
+		cmd.Stdout, cmd.Stderr = lockShared(cmd.Stdout, cmd.Stderr)
+	}
+	if teeOut != nil {
+		cmd.Stdout = teeWriter(cmd.Stdout, teeOut)
+	}
+	if teeErr != nil {
+		cmd.Stderr = teeWriter(cmd.Stderr, teeErr)
+	}
+	tio.watchOutputs()
+	tio.decodeOutputs()
+	if tio.rawStdout != nil {
+		cmd.Stdout, cmd.Stderr = lockShared(cmd.Stdout, cmd.Stderr)
+		cmd.Stdout = teeWriter(cmd.Stdout, tio.rawStdout)
+		cmd.Stderr = teeWriter(cmd.Stderr, tio.rawStderr)
+	}
+	if tio.duration != tio.Duration {
+		tio.debugf("duration %s is clamped to %s by the deadline", tio.Duration, tio.duration)
+	}
+	if err := cmd.Start(); err != nil {
+		tio.closeOutputs()
+		tio.debugf("failed to start command: %s", err)
 		return &Error{
 			ExitCode: wrapcommander.ResolveExitCode(err),
 			Err:      err,
 		}
 	}
+	tio.debugf("started command %q (pid: %d)", tio.Cmd.Path, tio.Cmd.Process.Pid)
 	return nil
 }
 
 func (tio *Timeout) wait(ctx context.Context) *ExitStatus {
 	ex := &ExitStatus{}
 	cmd := tio.getCmd()
-	exitChan := getExitChan(cmd)
+	exitChan := tio.getExitChan(cmd)
 	killCh := make(chan struct{}, 2)
 	done := make(chan struct{})
 	defer close(done)
 
 	delayedKill := func(dur time.Duration) {
-		select {
-		case <-done:
-			return
-		case <-time.After(dur):
-			killCh <- struct{}{}
-		}
+		tio.state.goFunc(func() {
+			timer := time.NewTimer(dur)
+			defer timer.Stop()
+			select {
+			case <-done:
+				return
+			case <-timer.C:
+				killCh <- struct{}{}
+			}
+		})
 	}
 
-	if tio.KillAfter > 0 {
-		go delayedKill(tio.Duration + tio.KillAfter)
-	}
+	tio.debugf("timer armed: %s", tio.duration)
+	wctx, wcancel := context.WithCancel(context.Background())
+	defer wcancel()
+	triggerCh := tio.startWatchers(wctx)
+	// set to nil after fired, since a closed channel is always selectable
+	ctxDone := ctx.Done()
+	closing := tio.state.closing()
 	for {
 		select {
 		case st := <-exitChan:
+			tio.closeOutputs()
 			ex.Code = wrapcommander.WaitStatusToExitCode(st)
 			ex.Signaled = st.Signaled()
+			tio.debugf("wait status received: exit status: %d, signaled: %t", st.ExitStatus(), ex.Signaled)
 			return ex
-		case <-time.After(tio.Duration):
-			tio.terminate()
+		case reason := <-triggerCh:
+			if ex.typ != exitTypeNormal {
+				tio.debugf("watcher triggered but already terminating: %s", reason)
+				continue
+			}
+			tio.debugf("timed out: %s, sending %s", reason, tio.signal())
+			if err := tio.terminate(); err != nil {
+				tio.debugf("failed to send signal: %s", err)
+			}
 			ex.typ = exitTypeTimedOut
+			ex.trigger = reason
+			if tio.KillAfter > 0 {
+				tio.debugf("kill timer armed: %s", tio.KillAfter)
+				delayedKill(tio.KillAfter)
+			}
 		case <-killCh:
-			tio.killall()
+			tio.debugf("kill timer fired, sending KILL")
+			if err := tio.killall(); err != nil {
+				tio.debugf("failed to kill process group: %s", err)
+			}
 			// just to make sure
 			cmd.Process.Kill()
 			ex.killed = true
 			if ex.typ != exitTypeCanceled {
 				ex.typ = exitTypeKilled
 			}
-		case <-ctx.Done():
+		case <-ctxDone:
+			ctxDone = nil
 			// XXX handling etx.Err()?
-			tio.terminate()
+			tio.debugf("context done (%s), sending %s", ctx.Err(), tio.signal())
+			if err := tio.terminate(); err != nil {
+				tio.debugf("failed to send signal: %s", err)
+			}
+			ex.typ = exitTypeCanceled
+			tio.debugf("kill timer armed: %s", tio.getKillAfterCancel())
+			delayedKill(tio.getKillAfterCancel())
+		case <-closing:
+			closing = nil
+			tio.debugf("closed while running, sending KILL")
+			tio.killall()
+			cmd.Process.Kill()
+			ex.killed = true
 			ex.typ = exitTypeCanceled
-			go delayedKill(tio.getKillAfterCancel())
 		}
 	}
 }
 
 func (tio *Timeout) getKillAfterCancel() time.Duration {
 	if tio.KillAfterCancel == 0 {
-		return 3 * time.Second
+		return defaultKillAfterCancel
 	}
 	return tio.KillAfterCancel
 }
 
-func getExitChan(cmd *exec.Cmd) chan syscall.WaitStatus {
-	ch := make(chan syscall.WaitStatus)
-	go func() {
+func (tio *Timeout) getExitChan(cmd *exec.Cmd) chan syscall.WaitStatus {
+	ch := make(chan syscall.WaitStatus, 1)
+	tio.state.goFunc(func() {
 		err := cmd.Wait()
 		st, _ := wrapcommander.ErrorToWaitStatus(err)
 		ch <- st
-	}()
+	})
 	return ch
 }
@@ -0,0 +1,29 @@
+// +build js
+
+package timeout
+
+import (
+	"os"
+	"os/exec"
+)
+
+func init() {
+	processSupported = false
+}
+
+func (tio *Timeout) getCmd() *exec.Cmd {
+	return tio.Cmd
+}
+
+func (tio *Timeout) terminate() error {
+	return ErrUnsupported
+}
+
+func (tio *Timeout) killall() error {
+	return ErrUnsupported
+}
+
+// XXX max RSS is not available on this platform
+func maxRSS(ps *os.ProcessState) int64 {
+	return 0
+}
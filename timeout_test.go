@@ -1,6 +1,7 @@
 package timeout
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
@@ -242,3 +243,70 @@ func TestRun_leak(t *testing.T) {
 		t.Errorf("goroutine may be leaked. before: %d, after: %d", beforeGoroutine, afterGoroutine)
 	}
 }
+
+func TestRun_tee(t *testing.T) {
+	var teeOut, teeErr bytes.Buffer
+	tio := &Timeout{
+		Duration:  10 * time.Second,
+		Cmd:       exec.Command(shellcmd, shellflag, "echo 1 && echo 2 1>&2"),
+		TeeStdout: &teeOut,
+		TeeStderr: &teeErr,
+	}
+	_, stdout, stderr, err := tio.Run()
+	if err != nil {
+		t.Errorf("error should be nil but: %s", err)
+	}
+	if strings.TrimSpace(stdout) != "1" || strings.TrimSpace(teeOut.String()) != "1" {
+		t.Errorf("stdout should be teed but: %q, %q", stdout, teeOut.String())
+	}
+	if strings.TrimSpace(stderr) != "2" || strings.TrimSpace(teeErr.String()) != "2" {
+		t.Errorf("stderr should be teed but: %q, %q", stderr, teeErr.String())
+	}
+}
+
+func TestRun_teeShared(t *testing.T) {
+	var tee bytes.Buffer
+	tio := &Timeout{
+		Duration:  10 * time.Second,
+		Cmd:       bothOutputsCmd(),
+		TeeStdout: &tee,
+		TeeStderr: &tee,
+	}
+	_, _, _, err := tio.Run()
+	if err != nil {
+		t.Errorf("error should be nil but: %s", err)
+	}
+	out := tee.String()
+	if strings.Count(out, "out") != 5 || strings.Count(out, "err") != 5 {
+		t.Errorf("both outputs should be teed to the shared writer but: %q", out)
+	}
+}
+
+func TestRun_teeSharedOutput(t *testing.T) {
+	var out, tee bytes.Buffer
+	cmd := bothOutputsCmd()
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	tio := &Timeout{
+		Duration:  10 * time.Second,
+		Cmd:       cmd,
+		TeeStdout: &tee,
+	}
+	if _, err := tio.RunContext(context.Background()); err != nil {
+		t.Errorf("error should be nil but: %s", err)
+	}
+	if strings.Count(out.String(), "out") != 5 || strings.Count(out.String(), "err") != 5 {
+		t.Errorf("both outputs should be written to the shared writer but: %q", out.String())
+	}
+	if strings.Count(tee.String(), "out") != 5 || strings.Contains(tee.String(), "err") {
+		t.Errorf("only stdout should be teed but: %q", tee.String())
+	}
+}
+
+// bothOutputsCmd writes "out" to stdout and "err" to stderr 5 times each
+func bothOutputsCmd() *exec.Cmd {
+	if isWin {
+		return exec.Command(shellcmd, shellflag, "for /l %i in (1,1,5) do @(echo out & echo err 1>&2)")
+	}
+	return exec.Command(shellcmd, shellflag, "for i in 1 2 3 4 5; do echo out; echo err 1>&2; done")
+}
@@ -1,9 +1,11 @@
-// +build !windows
+// +build !windows,!js
 
 package timeout
 
 import (
+	"os"
 	"os/exec"
+	"runtime"
 	"syscall"
 )
 
@@ -37,3 +39,16 @@ func (tio *Timeout) terminate() error {
 func (tio *Timeout) killall() error {
 	return syscall.Kill(-tio.Cmd.Process.Pid, syscall.SIGKILL)
 }
+
+// maxRSS returns the maximum resident set size of the process in bytes
+func maxRSS(ps *os.ProcessState) int64 {
+	ru, ok := ps.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	if runtime.GOOS == "darwin" {
+		return int64(ru.Maxrss)
+	}
+	// kilobytes on linux and bsds
+	return int64(ru.Maxrss) * 1024
+}
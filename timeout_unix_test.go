@@ -1,9 +1,12 @@
-// +build !windows
+// +build !windows,!js
 
 package timeout
 
 import (
+	"bytes"
+	"context"
 	"os/exec"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -71,3 +74,27 @@ func TestRunCommand_signaled(t *testing.T) {
 		})
 	}
 }
+
+func TestRunContext_cancelOnce(t *testing.T) {
+	var buf bytes.Buffer
+	tio := &Timeout{
+		Duration:        10 * time.Second,
+		KillAfterCancel: 500 * time.Millisecond,
+		Cmd:             exec.Command(stubCmd, "-trap", "SIGTERM", "-sleep", "3"),
+		// written from the goroutines of the kill timers too
+		DebugWriter: &lockedWriter{w: &buf},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	st, err := tio.RunContext(ctx)
+	if err != nil {
+		t.Fatalf("error should be nil but: %s", err)
+	}
+	if !st.IsCanceled() || !st.IsKilled() {
+		t.Errorf("should be canceled and killed: %+v", st)
+	}
+	// the done channel of the context keeps being selectable after closed
+	if n := strings.Count(buf.String(), "context done"); n != 1 {
+		t.Errorf("the command should be signaled once on cancel but %d times:\n%s", n, buf.String())
+	}
+}
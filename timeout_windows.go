@@ -1,24 +1,48 @@
 package timeout
 
 import (
+	"os"
 	"os/exec"
 	"strconv"
 	"syscall"
+
+	"golang.org/x/sys/windows"
 )
 
+const createNewProcessGroup = 0x00000200
+
+var procGenerateConsoleCtrlEvent = windows.NewLazySystemDLL("kernel32.dll").NewProc("GenerateConsoleCtrlEvent")
+
 func (tio *Timeout) getCmd() *exec.Cmd {
 	if !tio.Foreground && tio.Cmd.SysProcAttr == nil {
 		tio.Cmd.SysProcAttr = &syscall.SysProcAttr{
-			CreationFlags: syscall.CREATE_UNICODE_ENVIRONMENT | 0x00000200,
+			CreationFlags: syscall.CREATE_UNICODE_ENVIRONMENT | createNewProcessGroup,
 		}
 	}
 	return tio.Cmd
 }
 
+// terminate sends CTRL_BREAK_EVENT to the process group of the command, since
+// Process.Signal supports only os.Kill on Windows. The event can be sent only
+// when the command has its own process group, that is, not in Foreground.
 func (tio *Timeout) terminate() error {
-	return tio.Cmd.Process.Signal(tio.signal())
+	sig := tio.signal()
+	attr := tio.Cmd.SysProcAttr
+	if sig == os.Kill || attr == nil || attr.CreationFlags&createNewProcessGroup == 0 {
+		return tio.Cmd.Process.Signal(sig)
+	}
+	r, _, err := procGenerateConsoleCtrlEvent.Call(windows.CTRL_BREAK_EVENT, uintptr(tio.Cmd.Process.Pid))
+	if r == 0 {
+		return err
+	}
+	return nil
 }
 
 func (tio *Timeout) killall() error {
 	return exec.Command("taskkill", "/F", "/T", "/PID", strconv.Itoa(tio.Cmd.Process.Pid)).Run()
 }
+
+// XXX max RSS is not available on this platform
+func maxRSS(ps *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,41 @@
+package timeout
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/Songmu/wrapcommander"
+)
+
+// Validate checks the Timeout can be run without running the command actually.
+// It checks the command resolves to an executable and the options are valid
+// and permitted on the platform.
+func (tio *Timeout) Validate() error {
+	if !processSupported {
+		return &Error{ExitCode: exitUnknownErr, Err: ErrUnsupported}
+	}
+	if tio.Cmd == nil {
+		return &Error{ExitCode: exitUnknownErr, Err: fmt.Errorf("no command specified")}
+	}
+	if _, err := exec.LookPath(tio.Cmd.Path); err != nil {
+		exit := wrapcommander.ResolveExitCode(err)
+		if eerr, ok := err.(*exec.Error); ok && exit == exitUnknownErr {
+			// permission errors are wrapped by exec.Error
+			exit = wrapcommander.ResolveExitCode(eerr.Err)
+		}
+		return &Error{ExitCode: exit, Err: err}
+	}
+	if tio.Duration < 0 {
+		return &Error{ExitCode: exitUnknownErr, Err: fmt.Errorf("invalid duration: %s", tio.Duration)}
+	}
+	if tio.KillAfter < 0 {
+		return &Error{ExitCode: exitUnknownErr, Err: fmt.Errorf("invalid kill-after: %s", tio.KillAfter)}
+	}
+	if err := tio.validateNamespace(); err != nil {
+		return &Error{ExitCode: exitUnknownErr, Err: err}
+	}
+	if err := tio.validateWatchers(); err != nil {
+		return &Error{ExitCode: exitUnknownErr, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package timeout
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		tio       *Timeout
+		exit      int
+		skipOnWin bool
+	}{
+		{
+			name: "valid",
+			tio: &Timeout{
+				Duration: time.Second,
+				Cmd:      exec.Command(stubCmd),
+			},
+		},
+		{
+			name: "command not found",
+			tio: &Timeout{
+				Duration: time.Second,
+				Cmd:      exec.Command("testdata/command-not-found"),
+			},
+			exit:      127,
+			skipOnWin: true,
+		},
+		{
+			name: "command not executable",
+			tio: &Timeout{
+				Duration: time.Second,
+				Cmd:      exec.Command("testdata/dummy"),
+			},
+			exit:      126,
+			skipOnWin: true,
+		},
+		{
+			name: "command not found in PATH",
+			tio: &Timeout{
+				Duration: time.Second,
+				Cmd:      exec.Command("command-not-found-in-path"),
+			},
+			exit: 127,
+		},
+		{
+			name: "invalid duration",
+			tio: &Timeout{
+				Duration: -time.Second,
+				Cmd:      exec.Command(stubCmd),
+			},
+			exit: exitUnknownErr,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.skipOnWin && isWin {
+				t.Skipf("%s: skip on windows", tc.name)
+			}
+			err := tc.tio.Validate()
+			if exit := getExitCodeFromErr(err); exit != tc.exit {
+				t.Errorf("expected exitcode: %d, but: %d (%v)", tc.exit, exit, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package timeout
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Watcher watches a running command and triggers the termination of it.
+// Duration of Timeout is run as a WallClockWatcher too. When a Watcher
+// triggers, the command is terminated and killed after KillAfter if it is set.
+type Watcher interface {
+	// Watch blocks until the command should be terminated and returns the
+	// reason, which is recorded in ExitStatus. It must return an empty string
+	// when ctx is done.
+	Watch(ctx context.Context, target *WatchTarget) string
+}
+
+// WatchTarget is the information of the command passed to Watchers
+type WatchTarget struct {
+	Pid     int
+	StartAt time.Time
+
+	lastOutput int64 // unix nano
+}
+
+// LastOutput returns the time when the command wrote to stdout or stderr last.
+// It returns StartAt if the command has written nothing.
+func (wt *WatchTarget) LastOutput() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&wt.lastOutput))
+}
+
+func (wt *WatchTarget) touch() {
+	atomic.StoreInt64(&wt.lastOutput, time.Now().UnixNano())
+}
+
+type activityWriter struct {
+	w      io.Writer
+	target *WatchTarget
+}
+
+func (aw *activityWriter) Write(p []byte) (int, error) {
+	aw.target.touch()
+	if aw.w == nil {
+		return len(p), nil
+	}
+	return aw.w.Write(p)
+}
+
+// watchOutputs wraps the outputs of the command to record the last output
+// time. Only IdleWatcher needs it, and the outputs are left as they are
+// otherwise not to make exec.Cmd pass pipes to the command.
+func (tio *Timeout) watchOutputs() {
+	tio.target = &WatchTarget{}
+	if !tio.watchesIdle() {
+		return
+	}
+	cmd := tio.getCmd()
+	sameWriter := cmd.Stdout == cmd.Stderr
+	cmd.Stdout = &activityWriter{w: cmd.Stdout, target: tio.target}
+	if sameWriter {
+		cmd.Stderr = cmd.Stdout
+	} else {
+		cmd.Stderr = &activityWriter{w: cmd.Stderr, target: tio.target}
+	}
+}
+
+// startWatchers starts Watchers, with a WallClockWatcher for Duration ahead of
+// them, and returns the channel to receive the reason when one of them triggers
+func (tio *Timeout) startWatchers(ctx context.Context) <-chan string {
+	tio.target.Pid = tio.Cmd.Process.Pid
+	tio.target.StartAt = time.Now()
+	tio.target.touch()
+
+	watchers := append([]Watcher{&WallClockWatcher{Duration: tio.duration}}, tio.Watchers...)
+	// buffered not to block the watchers after the first trigger
+	ch := make(chan string, len(watchers))
+	for _, w := range watchers {
+		w := w
+		tio.state.goFunc(func() {
+			if reason := w.Watch(ctx, tio.target); reason != "" {
+				ch <- reason
+			}
+		})
+	}
+	return ch
+}
+
+func (tio *Timeout) watchesIdle() bool {
+	for _, w := range tio.Watchers {
+		if _, ok := w.(*IdleWatcher); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (tio *Timeout) validateWatchers() error {
+	for _, w := range tio.Watchers {
+		if v, ok := w.(interface{ validate() error }); ok {
+			if err := v.validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+const defaultWatchInterval = time.Second
+
+func watchInterval(d time.Duration) time.Duration {
+	if d <= 0 {
+		return defaultWatchInterval
+	}
+	return d
+}
+
+// poll calls f every interval until it returns a reason or ctx is done
+func poll(ctx context.Context, interval time.Duration, f func() string) string {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ""
+		case <-ticker.C:
+			if reason := f(); reason != "" {
+				return reason
+			}
+		}
+	}
+}
+
+// WallClockWatcher triggers when the command runs longer than Duration
+type WallClockWatcher struct {
+	Duration time.Duration
+}
+
+// Watch implements Watcher
+func (w *WallClockWatcher) Watch(ctx context.Context, target *WatchTarget) string {
+	timer := time.NewTimer(time.Until(target.StartAt.Add(w.Duration)))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ""
+	case <-timer.C:
+		return fmt.Sprintf("wall clock time exceeded %s", w.Duration)
+	}
+}
+
+// IdleWatcher triggers when the command writes nothing to stdout and stderr
+// for Duration
+type IdleWatcher struct {
+	Duration time.Duration
+}
+
+// Watch implements Watcher
+func (w *IdleWatcher) Watch(ctx context.Context, target *WatchTarget) string {
+	for {
+		timer := time.NewTimer(time.Until(target.LastOutput().Add(w.Duration)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ""
+		case <-timer.C:
+		}
+		if !time.Now().Before(target.LastOutput().Add(w.Duration)) {
+			return fmt.Sprintf("no output for %s", w.Duration)
+		}
+	}
+}
+
+// HeartbeatWatcher triggers when the modification time of the file at Path,
+// which the command is supposed to touch periodically, gets older than
+// Interval. The start time of the command is used while the file does not exist.
+type HeartbeatWatcher struct {
+	Path     string
+	Interval time.Duration
+}
+
+// Watch implements Watcher
+func (w *HeartbeatWatcher) Watch(ctx context.Context, target *WatchTarget) string {
+	return poll(ctx, watchInterval(w.Interval/4), func() string {
+		last := target.StartAt
+		if fi, err := os.Stat(w.Path); err == nil && fi.ModTime().After(last) {
+			last = fi.ModTime()
+		}
+		if time.Since(last) > w.Interval {
+			return fmt.Sprintf("no heartbeat on %s for %s", w.Path, w.Interval)
+		}
+		return ""
+	})
+}
+
+// MemoryWatcher triggers when the resident set size of the command gets larger
+// than Limit bytes. The processes in the process group of the command are
+// summed up, so the children of a shell are measured too. It is supported on
+// Linux only.
+type MemoryWatcher struct {
+	Limit    int64
+	Interval time.Duration
+}
+
+// Watch implements Watcher
+func (w *MemoryWatcher) Watch(ctx context.Context, target *WatchTarget) string {
+	if w.validate() != nil {
+		<-ctx.Done()
+		return ""
+	}
+	return poll(ctx, watchInterval(w.Interval), func() string {
+		rss, err := processRSS(target.Pid)
+		if err == nil && rss > w.Limit {
+			return fmt.Sprintf("memory usage %d bytes exceeded %d bytes", rss, w.Limit)
+		}
+		return ""
+	})
+}
+
+func (w *MemoryWatcher) validate() error {
+	if !processStatSupported {
+		return fmt.Errorf("memory watcher is not supported on this platform")
+	}
+	return nil
+}
+
+// CPUTimeWatcher triggers when the CPU time (user and system) consumed by the
+// command gets longer than Limit. The processes in the process group of the
+// command are summed up like MemoryWatcher. It is supported on Linux only.
+type CPUTimeWatcher struct {
+	Limit    time.Duration
+	Interval time.Duration
+}
+
+// Watch implements Watcher
+func (w *CPUTimeWatcher) Watch(ctx context.Context, target *WatchTarget) string {
+	if w.validate() != nil {
+		<-ctx.Done()
+		return ""
+	}
+	return poll(ctx, watchInterval(w.Interval), func() string {
+		cpu, err := processCPUTime(target.Pid)
+		if err == nil && cpu > w.Limit {
+			return fmt.Sprintf("CPU time %s exceeded %s", cpu, w.Limit)
+		}
+		return ""
+	})
+}
+
+func (w *CPUTimeWatcher) validate() error {
+	if !processStatSupported {
+		return fmt.Errorf("CPU time watcher is not supported on this platform")
+	}
+	return nil
+}
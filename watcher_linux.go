@@ -0,0 +1,109 @@
+package timeout
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+)
+
+const processStatSupported = true
+
+// clock ticks of /proc/<pid>/stat, which is fixed at 100 (USER_HZ) for userspace
+const userHZ = 100
+
+// processRSS returns the resident set size of the process group led by pid,
+// or of the process itself if it does not lead a group
+func processRSS(pid int) (int64, error) {
+	var total int64
+	for _, p := range processGroup(pid) {
+		b, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/statm", p))
+		if err != nil {
+			if p == pid {
+				return 0, err
+			}
+			// exited in the meantime
+			continue
+		}
+		fields := bytes.Fields(b)
+		if len(fields) < 2 {
+			return 0, fmt.Errorf("unexpected format of statm: %q", b)
+		}
+		pages, err := strconv.ParseInt(string(fields[1]), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		total += pages * int64(os.Getpagesize())
+	}
+	return total, nil
+}
+
+// processCPUTime returns the CPU time of the process group led by pid, or of
+// the process itself if it does not lead a group. The CPU times of the
+// children which have exited and been waited for are included.
+func processCPUTime(pid int) (time.Duration, error) {
+	var ticks int64
+	for _, p := range processGroup(pid) {
+		fields, err := readStat(p)
+		if err != nil {
+			if p == pid {
+				return 0, err
+			}
+			continue
+		}
+		// utime, stime, cutime and cstime are the 14th to 17th fields
+		if len(fields) < 15 {
+			return 0, fmt.Errorf("unexpected format of stat of %d", p)
+		}
+		for _, f := range fields[11:15] {
+			t, err := strconv.ParseInt(string(f), 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			ticks += t
+		}
+	}
+	return time.Duration(ticks) * time.Second / userHZ, nil
+}
+
+// readStat returns the fields of /proc/<pid>/stat after the command name,
+// which start from the 3rd field (state)
+func readStat(pid int) ([][]byte, error) {
+	b, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+	// the command name in parentheses may contain spaces
+	i := bytes.LastIndexByte(b, ')')
+	if i < 0 {
+		return nil, fmt.Errorf("unexpected format of stat: %q", b)
+	}
+	return bytes.Fields(b[i+1:]), nil
+}
+
+// processGroup returns pid and the other processes in the group led by pid.
+// The command leads its own group unless its SysProcAttr is given.
+func processGroup(pid int) []int {
+	pids := []int{pid}
+	names, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return pids
+	}
+	for _, fi := range names {
+		p, err := strconv.Atoi(fi.Name())
+		if err != nil || p == pid {
+			continue
+		}
+		fields, err := readStat(p)
+		// pgrp is the 5th field
+		if err != nil || len(fields) < 3 {
+			continue
+		}
+		if pgrp, err := strconv.Atoi(string(fields[2])); err == nil && pgrp == pid {
+			pids = append(pids, p)
+		}
+	}
+	return pids
+}
@@ -0,0 +1,52 @@
+package timeout
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun_resourceWatchers(t *testing.T) {
+	testCases := []struct {
+		name    string
+		watcher Watcher
+	}{
+		{
+			name:    "memory",
+			watcher: &MemoryWatcher{Limit: 1, Interval: 10 * time.Millisecond},
+		},
+		{
+			name:    "cpu time",
+			watcher: &CPUTimeWatcher{Limit: 100 * time.Millisecond, Interval: 10 * time.Millisecond},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tio := &Timeout{
+				Duration: 10 * time.Second,
+				// the busy loop runs in a child of the shell
+				Cmd:      exec.Command(shellcmd, shellflag, "sh -c 'while :; do :; done'; true"),
+				Watchers: []Watcher{tc.watcher},
+			}
+			st, _, _, err := tio.Run()
+			if err != nil {
+				t.Fatalf("error should be nil but: %s", err)
+			}
+			if !st.IsTimedOut() || !strings.Contains(st.Trigger(), "exceeded") {
+				t.Errorf("should be triggered but: %q", st.Trigger())
+			}
+		})
+	}
+}
+
+func TestProcessStat(t *testing.T) {
+	rss, err := processRSS(os.Getpid())
+	if err != nil || rss <= 0 {
+		t.Errorf("invalid rss: %d, %v", rss, err)
+	}
+	if _, err := processCPUTime(os.Getpid()); err != nil {
+		t.Errorf("error should be nil but: %s", err)
+	}
+}
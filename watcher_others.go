@@ -0,0 +1,20 @@
+// +build !linux
+
+package timeout
+
+import (
+	"errors"
+	"time"
+)
+
+const processStatSupported = false
+
+var errProcessStat = errors.New("process statistics are not supported on this platform")
+
+func processRSS(pid int) (int64, error) {
+	return 0, errProcessStat
+}
+
+func processCPUTime(pid int) (time.Duration, error) {
+	return 0, errProcessStat
+}
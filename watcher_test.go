@@ -0,0 +1,190 @@
+package timeout
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stubWatcher struct {
+	after  time.Duration
+	reason string
+}
+
+func (w *stubWatcher) Watch(ctx context.Context, target *WatchTarget) string {
+	select {
+	case <-ctx.Done():
+		return ""
+	case <-time.After(w.after):
+		return w.reason
+	}
+}
+
+func TestRun_watchers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		name     string
+		cmd      *exec.Cmd
+		watchers []Watcher
+		trigger  string
+		timedOut bool
+	}{
+		{
+			name:     "not triggered",
+			cmd:      exec.Command(stubCmd),
+			watchers: []Watcher{&stubWatcher{after: 3 * time.Second, reason: "stub"}},
+		},
+		{
+			name: "first trigger wins",
+			cmd:  exec.Command(stubCmd, "-sleep", "3"),
+			watchers: []Watcher{
+				&stubWatcher{after: 500 * time.Millisecond, reason: "second"},
+				&stubWatcher{after: 100 * time.Millisecond, reason: "first"},
+			},
+			trigger:  "first",
+			timedOut: true,
+		},
+		{
+			name:     "wall clock",
+			cmd:      exec.Command(stubCmd, "-sleep", "3"),
+			watchers: []Watcher{&WallClockWatcher{Duration: 100 * time.Millisecond}},
+			trigger:  "wall clock time exceeded 100ms",
+			timedOut: true,
+		},
+		{
+			name:     "idle",
+			cmd:      exec.Command(stubCmd, "-sleep", "3"),
+			watchers: []Watcher{&IdleWatcher{Duration: 100 * time.Millisecond}},
+			trigger:  "no output for 100ms",
+			timedOut: true,
+		},
+		{
+			name: "heartbeat",
+			cmd:  exec.Command(stubCmd, "-sleep", "3"),
+			watchers: []Watcher{&HeartbeatWatcher{
+				Path:     filepath.Join(dir, "heartbeat"),
+				Interval: 100 * time.Millisecond,
+			}},
+			trigger:  "no heartbeat on",
+			timedOut: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tio := &Timeout{
+				Duration: 10 * time.Second,
+				Cmd:      tc.cmd,
+				Watchers: tc.watchers,
+			}
+			start := time.Now()
+			st, _, _, err := tio.Run()
+			if err != nil {
+				t.Fatalf("error should be nil but: %s", err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("took too long: %s", elapsed)
+			}
+			if !strings.HasPrefix(st.Trigger(), tc.trigger) || (tc.trigger == "") != (st.Trigger() == "") {
+				t.Errorf("expected trigger: %q, but: %q", tc.trigger, st.Trigger())
+			}
+			if st.IsTimedOut() != tc.timedOut {
+				t.Errorf("expected timed out: %t, but: %t", tc.timedOut, st.IsTimedOut())
+			}
+		})
+	}
+}
+
+func TestRun_watcherKillAfter(t *testing.T) {
+	if isWin {
+		t.Skip("skip on windows")
+	}
+	tio := &Timeout{
+		Duration:  10 * time.Second,
+		KillAfter: 100 * time.Millisecond,
+		Cmd:       exec.Command(stubCmd, "-trap", "SIGTERM", "-sleep", "3"),
+		Watchers:  []Watcher{&stubWatcher{after: 100 * time.Millisecond, reason: "stub"}},
+	}
+	if exit := tio.RunSimple(false); exit != exitKilled {
+		t.Errorf("expected exitcode: %d, but: %d", exitKilled, exit)
+	}
+}
+
+type invalidWatcher struct {
+	stubWatcher
+}
+
+func (w *invalidWatcher) validate() error {
+	return errors.New("invalid watcher")
+}
+
+func TestRun_invalidWatcher(t *testing.T) {
+	tio := &Timeout{
+		Duration: 10 * time.Second,
+		Cmd:      exec.Command(stubCmd),
+		Watchers: []Watcher{&invalidWatcher{}},
+	}
+	_, _, _, err := tio.Run()
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("error should be *Error but: %#v", err)
+	}
+	if e.ExitCode != exitUnknownErr {
+		t.Errorf("expected exitcode: %d, but: %d", exitUnknownErr, e.ExitCode)
+	}
+	if tio.Cmd.Process != nil {
+		t.Errorf("command should not be started")
+	}
+}
+
+func TestRun_watcherOutputs(t *testing.T) {
+	devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devnull.Close()
+
+	testCases := []struct {
+		name     string
+		watchers []Watcher
+		wrapped  bool
+	}{
+		{
+			name:     "wall clock",
+			watchers: []Watcher{&WallClockWatcher{Duration: 3 * time.Second}},
+		},
+		{
+			name:     "idle",
+			watchers: []Watcher{&IdleWatcher{Duration: 3 * time.Second}},
+			wrapped:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := exec.Command(stubCmd)
+			cmd.Stdout = devnull
+			tio := &Timeout{
+				Duration: 10 * time.Second,
+				Cmd:      cmd,
+				Watchers: tc.watchers,
+			}
+			if _, err := tio.RunContext(context.Background()); err != nil {
+				t.Fatalf("error should be nil but: %s", err)
+			}
+			if wrapped := cmd.Stdout != devnull; wrapped != tc.wrapped {
+				t.Errorf("expected wrapped: %t, but: %t", tc.wrapped, wrapped)
+			}
+		})
+	}
+}